@@ -1,8 +1,23 @@
 package main
 
 import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/wankhede04/blockswap.weather/weather-srv/aggregator"
+	"github.com/wankhede04/blockswap.weather/weather-srv/auth"
+	"github.com/wankhede04/blockswap.weather/weather-srv/concurrency"
 	"github.com/wankhede04/blockswap.weather/weather-srv/config"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/health"
+	"github.com/wankhede04/blockswap.weather/weather-srv/logging"
+	"github.com/wankhede04/blockswap.weather/weather-srv/merkle"
+	"github.com/wankhede04/blockswap.weather/weather-srv/stream"
+	"github.com/wankhede04/blockswap.weather/weather-srv/throttle"
+	"github.com/wankhede04/blockswap.weather/weather-srv/tracing"
 	weatherservice "github.com/wankhede04/blockswap.weather/weather-srv/weather-service"
+	"github.com/wankhede04/blockswap.weather/weather-srv/webhook"
 	"github.com/wankhede04/blockswap.weather/weather-srv/worker"
 
 	"github.com/wankhede04/blockswap.weather/weather-srv/membership/app"
@@ -21,33 +36,211 @@ func toWorkerConfig(config config.WorkerConfig) worker.WorkerConfig {
 	}
 }
 
-func main() {
-	logger := logrus.New()
+// toWebhookConfig converts the webhook configuration from the application's config package to the webhook.Config.
+func toWebhookConfig(config config.WebhookConfig) webhook.Config {
+	return webhook.Config{
+		MaxAttempts:  config.MaxAttempts,
+		BaseBackoff:  config.BaseBackoff,
+		MaxBackoff:   config.MaxBackoff,
+		PollInterval: config.PollInterval,
+		Timeout:      config.Timeout,
+	}
+}
+
+// toStreamConfig converts the report stream configuration from the application's config package to the stream.Config.
+func toStreamConfig(config config.StreamConfig) stream.Config {
+	return stream.Config{
+		Interval:  config.Interval,
+		BatchSize: config.BatchSize,
+	}
+}
+
+// toAggregationConfig converts the aggregation configuration from the application's config package to the aggregator.Config.
+func toAggregationConfig(config config.AggregationConfig) aggregator.Config {
+	return aggregator.Config{
+		WindowSizes:      config.WindowSizes,
+		Interval:         config.Interval,
+		BatchSize:        config.BatchSize,
+		ScoreWindow:      config.ScoreWindow,
+		OutlierThreshold: config.OutlierThreshold,
+		MinReporters:     config.MinReporters,
+		ReputationWindow: config.ReputationWindow,
+	}
+}
+
+// toMerkleConfig converts the Merkle configuration from the application's config package to the merkle.Config.
+func toMerkleConfig(config config.MerkleConfig) merkle.Config {
+	return merkle.Config{
+		EpochLength: config.EpochLength,
+		Grace:       config.Grace,
+		Interval:    config.Interval,
+	}
+}
+
+// toAuthConfig converts the session configuration from the application's config package to the auth.Config.
+func toAuthConfig(config config.AuthConfig) auth.Config {
+	return auth.Config{
+		Domain:      config.Domain,
+		TokenSecret: config.TokenSecret,
+		AccessTTL:   config.AccessTTL,
+		RefreshTTL:  config.RefreshTTL,
+		NonceTTL:    config.NonceTTL,
+	}
+}
+
+// toThrottleConfig converts the throttling configuration from the application's config package to the throttle.Config.
+func toThrottleConfig(config config.ThrottleConfig) throttle.Config {
+	return throttle.Config{
+		IPRate:       config.IPRate,
+		IPBurst:      config.IPBurst,
+		SubnetRate:   config.SubnetRate,
+		SubnetBurst:  config.SubnetBurst,
+		IPv4Prefix:   config.IPv4Prefix,
+		IPv6Prefix:   config.IPv6Prefix,
+		BanThreshold: config.BanThreshold,
+		BanWindow:    config.BanWindow,
+		BanDuration:  config.BanDuration,
+		MaxBodyBytes: config.MaxBodyBytes,
+	}
+}
+
+// toHealthConfig converts the readiness check configuration from the application's config package to the health.Config.
+func toHealthConfig(config config.HealthConfig) health.Config {
+	return health.Config{
+		Timeout:    config.Timeout,
+		MaxHeadLag: config.MaxHeadLag,
+	}
+}
+
+// toTracingConfig converts the tracing configuration from the application's config package to the tracing.Config.
+func toTracingConfig(config config.TracingConfig) tracing.Config {
+	return tracing.Config{
+		Endpoint:    config.Endpoint,
+		Insecure:    config.Insecure,
+		ServiceName: config.ServiceName,
+		SampleRatio: config.SampleRatio,
+	}
+}
 
+// toLoggingConfig converts the logger configuration from the application's config package to the logging.Config.
+func toLoggingConfig(config config.LoggerConfig) logging.Config {
+	return logging.Config{
+		Level:  config.Level,
+		Format: config.Format,
+	}
+}
+
+// toConcurrencyConfig converts the report concurrency configuration from the application's config package to the concurrency.Config.
+func toConcurrencyConfig(config config.ConcurrencyConfig) concurrency.Config {
+	return concurrency.Config{
+		MaxInFlight:  config.MaxInFlight,
+		MaxQueue:     config.MaxQueue,
+		QueueTimeout: config.QueueTimeout,
+		RetryAfter:   config.RetryAfter,
+	}
+}
+
+// toDBPoolConfig converts the database connection pool configuration from the application's config package to the db.PoolConfig.
+func toDBPoolConfig(config config.DBPoolConfig) db.PoolConfig {
+	return db.PoolConfig{
+		MaxOpenConns:    config.MaxOpenConns,
+		MaxIdleConns:    config.MaxIdleConns,
+		ConnMaxLifetime: config.ConnMaxLifetime,
+		AcquireTimeout:  config.AcquireTimeout,
+	}
+}
+
+// toShutdownConfig converts the shutdown configuration from the application's config package to the app.ShutdownConfig.
+func toShutdownConfig(config config.ShutdownConfig) app.ShutdownConfig {
+	return app.ShutdownConfig{
+		HTTPDrainTimeout: config.HTTPDrainTimeout,
+		GRPCDrainTimeout: config.GRPCDrainTimeout,
+		StopTimeout:      config.StopTimeout,
+	}
+}
+
+func main() {
 	// Read the application configuration
 	cfg := config.NewViperConfig()
 
+	// Every component logs through the logger configured by logger-level and logger-format
+	logger, err := logging.New(toLoggingConfig(cfg.ReadLoggerConfig()))
+	if err != nil {
+		logrus.Fatalf("Invalid logger configuration %s", err.Error())
+	}
+
 	// Read the database configuration from the application config
 	postgresDbConfig := cfg.ReadDBConfig()
 	dbURL := postgresDbConfig.AsPostgresDbUrl()
+	dbPoolConfig := toDBPoolConfig(cfg.ReadDBPoolConfig())
 
 	// Read the service URL from the application config
 	srvURL := cfg.ReadServiceConfig()
 
+	// Read the gRPC server URL from the application config, empty when the gRPC API is disabled
+	grpcURL := cfg.ReadGRPCConfig()
+
 	// Read the worker configurations from the application config and convert to worker.WorkerConfig
 	workersCfg := cfg.ReadWorkersConfig()
 	workerConfigs := toWorkerConfig(workersCfg)
 
+	// Read the webhook delivery, report stream, aggregation, Merkle, session, admin, idempotency, throttling and readiness configurations from the application config
+	webhookConfig := toWebhookConfig(cfg.ReadWebhookConfig())
+	streamConfig := toStreamConfig(cfg.ReadStreamConfig())
+	aggregationConfig := toAggregationConfig(cfg.ReadAggregationConfig())
+	merkleConfig := toMerkleConfig(cfg.ReadMerkleConfig())
+	authConfig := toAuthConfig(cfg.ReadAuthConfig())
+	adminConfig := cfg.ReadAdminConfig()
+	idempotencyConfig := cfg.ReadIdempotencyConfig()
+	throttleCfg := cfg.ReadThrottleConfig()
+	healthConfig := toHealthConfig(cfg.ReadHealthConfig())
+
+	// Keys and secrets of the configuration must never reach the logs
+	logging.RegisterSecret(adminConfig.APIKey)
+	logging.RegisterSecret(authConfig.TokenSecret)
+
+	// Export spans before anything is traced, spans still buffered are flushed on exit
+	shutdownTracing, err := tracing.Init(context.Background(), toTracingConfig(cfg.ReadTracingConfig()))
+	if err != nil {
+		logger.Panicf("Unable to initialise tracing %s", err.Error())
+	}
+	flushTracing := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorf("Unable to flush spans %s", err.Error())
+		}
+	}
+
 	// Create a new instance of the WeatherService
-	weatherservice, err := weatherservice.NewWeatherService(dbURL, logger, &workerConfigs, 10)
+	weatherservice, err := weatherservice.NewWeatherService(weatherservice.Options{
+		DatabaseURL:    dbURL,
+		Pool:           dbPoolConfig,
+		Logger:         logger,
+		Worker:         workerConfigs,
+		Webhooks:       webhookConfig,
+		Stream:         streamConfig,
+		Aggregation:    aggregationConfig,
+		Merkle:         merkleConfig,
+		Auth:           authConfig,
+		Throttle:       toThrottleConfig(throttleCfg),
+		Health:         healthConfig,
+		Concurrency:    toConcurrencyConfig(cfg.ReadConcurrencyConfig()),
+		AdminKey:       adminConfig.APIKey,
+		IdempotencyTTL: idempotencyConfig.TTL,
+	})
 	if err != nil {
 		logger.Panicf("Unable to create weather service %s", err.Error())
 	}
-	defer weatherservice.Close()
 
-	// Create a new instance of the application
-	app := app.NewApp(logger, srvURL, weatherservice)
+	// Create a new instance of the application, its routes are registered before it listens
+	app := app.NewApp(logger, srvURL, grpcURL, throttleCfg.TrustedProxies, toShutdownConfig(cfg.ReadShutdownConfig()), weatherservice)
 
-	// Run the application
-	app.Run()
+	// Run the application until it is stopped, it closes the weather service and the database on its way out
+	err = app.Run()
+	flushTracing()
+	if err != nil {
+		logger.Errorf("Weather Service failed %s", err.Error())
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,13 @@
+#!/bin/sh
+# Vendors the swagger-ui-dist release pinned in weather-srv/openapi/swagger-ui/VERSION, the assets are embedded in
+# the binary so that /v1/docs loads nothing from a CDN.
+set -e
+
+dir="$(cd "$(dirname "$0")/../weather-srv/openapi/swagger-ui" && pwd)"
+version="$(cat "$dir/VERSION")"
+tmp="$(mktemp -d)"
+trap 'rm -rf "$tmp"' EXIT
+
+(cd "$tmp" && npm pack "swagger-ui-dist@$version" >/dev/null && tar -xzf "swagger-ui-dist-$version.tgz")
+cp "$tmp/package/swagger-ui.css" "$tmp/package/swagger-ui-bundle.js" "$tmp/package/LICENSE" "$dir/"
+echo "Vendored swagger-ui-dist $version into $dir"
@@ -0,0 +1,320 @@
+package aggregator
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultInterval         = 10 * time.Second
+	DefaultBatchSize        = 1000
+	DefaultOutlierThreshold = 3.5
+	DefaultMinReporters     = 3
+	DefaultReputationWindow = 100
+)
+
+// DefaultWindowSizes are the window lengths used when the configuration has none
+var DefaultWindowSizes = []time.Duration{5 * time.Minute, time.Hour}
+
+// Config holds the aggregation settings
+type Config struct {
+	WindowSizes      []time.Duration // Fixed window lengths reports are rolled up into
+	Interval         time.Duration   // Interval between two aggregation runs
+	BatchSize        int             // Maximum number of new reports processed per run
+	ScoreWindow      time.Duration   // Window length reports are scored against consensus in, one of WindowSizes
+	OutlierThreshold float64         // Deviation above which a value is an outlier
+	MinReporters     int             // Distinct reporters needed in a window before values are scored
+	ReputationWindow int             // Number of recent scored values a member's reputation is computed over
+}
+
+// window identifies the reports of a region within a fixed window
+type window struct {
+	start  time.Time
+	size   time.Duration
+	region string
+}
+
+// Aggregator rolls committed reports up into fixed windows per region. Every run picks up the reports
+// marked as pending when they were committed and recomputes the windows they fall in, so late reports,
+// including those of slow transactions, update rollups that were already stored.
+type Aggregator struct {
+	DataBase *db.PostgresDataBase
+	Logger   *logrus.Logger
+	config   Config
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	wg       sync.WaitGroup // Running loop, waited for by Stop
+}
+
+// NewAggregator creates a new Aggregator instance, reports are scored in the smallest window unless
+// ScoreWindow is one of WindowSizes
+func NewAggregator(database *db.PostgresDataBase, logger *logrus.Logger, cfg Config) *Aggregator {
+	if len(cfg.WindowSizes) == 0 {
+		cfg.WindowSizes = DefaultWindowSizes
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if !containsDuration(cfg.WindowSizes, cfg.ScoreWindow) {
+		cfg.ScoreWindow = minDuration(cfg.WindowSizes)
+	}
+	if cfg.OutlierThreshold <= 0 {
+		cfg.OutlierThreshold = DefaultOutlierThreshold
+	}
+	if cfg.MinReporters <= 0 {
+		cfg.MinReporters = DefaultMinReporters
+	}
+	if cfg.ReputationWindow <= 0 {
+		cfg.ReputationWindow = DefaultReputationWindow
+	}
+	ctx, cancelFn := context.WithCancel(context.Background())
+	return &Aggregator{
+		DataBase: database,
+		Logger:   logger,
+		config:   cfg,
+		ctx:      ctx,
+		cancelFn: cancelFn,
+	}
+}
+
+// containsDuration reports whether d is one of sizes
+func containsDuration(sizes []time.Duration, d time.Duration) bool {
+	for _, size := range sizes {
+		if size == d {
+			return true
+		}
+	}
+	return false
+}
+
+// minDuration returns the smallest of sizes
+func minDuration(sizes []time.Duration) time.Duration {
+	smallest := sizes[0]
+	for _, size := range sizes[1:] {
+		if size < smallest {
+			smallest = size
+		}
+	}
+	return smallest
+}
+
+// ReputationWindow returns the number of recent scored values reputations are computed over
+func (a *Aggregator) ReputationWindow() int {
+	return a.config.ReputationWindow
+}
+
+// Run starts aggregating reports until Stop is called
+func (a *Aggregator) Run() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.processReports()
+	}()
+}
+
+// Stop stops aggregating reports, the next start resumes with the reports still pending.
+// It returns once the batch being aggregated is done.
+func (a *Aggregator) Stop() {
+	a.cancelFn()
+	a.wg.Wait()
+}
+
+// processReports periodically aggregates the new reports
+func (a *Aggregator) processReports() {
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for {
+				processed, err := a.aggregateNewReports()
+				if err != nil {
+					a.Logger.Errorf("Error aggregating reports: %v", err)
+					break
+				}
+				// Keep going while there is a backlog, unless stopping
+				if processed < a.config.BatchSize || a.ctx.Err() != nil {
+					break
+				}
+			}
+		case <-a.ctx.Done():
+			a.Logger.Info("Aggregator has stopped")
+			return
+		}
+	}
+}
+
+// aggregateNewReports recomputes the windows of the pending reports and clears their marks. Marks are created in
+// the transaction of their report, so a report committed after a later one is still picked up.
+func (a *Aggregator) aggregateNewReports() (int, error) {
+	records, err := db.FindPendingReports(a.DataBase.DB, a.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	dirty := make(map[window]struct{})
+	for _, r := range records {
+		for _, size := range a.config.WindowSizes {
+			dirty[window{start: r.CreatedAt.UTC().Truncate(size), size: size, region: r.Region}] = struct{}{}
+		}
+	}
+	// Score windows go first, the other windows count the outliers flagged in them
+	windows := make([]window, 0, len(dirty))
+	for w := range dirty {
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].size == a.config.ScoreWindow && windows[j].size != a.config.ScoreWindow
+	})
+	for _, w := range windows {
+		if err := a.Recompute(w.start, w.size, w.region); err != nil {
+			return 0, err
+		}
+	}
+
+	ids := make([]uint, len(records))
+	for i, r := range records {
+		ids[i] = r.ID
+	}
+	if err := db.DeletePendingAggregations(a.DataBase.DB, ids); err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// Recompute rolls up every report of region within the window starting at start and stores one rollup per metric.
+// Reports in the score window are scored first, so the rollups are weighted with the updated reputations. Other
+// windows count the values flagged as outliers when their score windows were recomputed.
+func (a *Aggregator) Recompute(start time.Time, size time.Duration, region string) error {
+	records, err := db.FindReportsBetween(a.DataBase.DB, start, start.Add(size), region)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	samples := make(map[string][]Sample)
+	var memberIDs []uint
+	for _, r := range records {
+		for metric, v := range ParseReport(r.Report) {
+			samples[metric] = append(samples[metric], Sample{ReportID: r.ID, MembershipID: r.MembershipID, Value: v})
+		}
+		memberIDs = append(memberIDs, r.MembershipID)
+	}
+
+	var outliers map[string]map[uint]bool
+	if size == a.config.ScoreWindow {
+		outliers, err = a.scoreWindow(samples)
+	} else {
+		outliers, err = a.storedOutliers(start, size, region)
+	}
+	if err != nil {
+		return err
+	}
+
+	reputations, err := db.FindReputations(a.DataBase.DB, memberIDs)
+	if err != nil {
+		return err
+	}
+
+	for metric, metricSamples := range samples {
+		values := make([]float64, len(metricSamples))
+		weights := make([]float64, len(metricSamples))
+		reporters := make(map[uint]struct{})
+		var outlierCount int64
+		for i, sample := range metricSamples {
+			values[i] = sample.Value
+			weights[i] = reputations[sample.MembershipID]
+			reporters[sample.MembershipID] = struct{}{}
+			if outliers[metric][sample.ReportID] {
+				outlierCount++
+			}
+		}
+
+		weightedMean := WeightedMean(values, weights)
+		stats := ComputeStats(values)
+		rollup := db.ObservationRollup{
+			WindowStart:   start,
+			WindowSeconds: int64(size / time.Second),
+			Region:        region,
+			Metric:        metric,
+			Count:         stats.Count,
+			ReporterCount: int64(len(reporters)),
+			Mean:          stats.Mean,
+			WeightedMean:  weightedMean,
+			Median:        stats.Median,
+			Min:           stats.Min,
+			Max:           stats.Max,
+			OutlierCount:  outlierCount,
+		}
+		if err := db.UpsertObservationRollup(a.DataBase.DB, &rollup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedOutliers returns the outlier flag of the scored reports of region within the window per metric
+func (a *Aggregator) storedOutliers(start time.Time, size time.Duration, region string) (map[string]map[uint]bool, error) {
+	scores, err := db.FindOutlierScores(a.DataBase.DB, start, start.Add(size), region)
+	if err != nil {
+		return nil, err
+	}
+	outliers := make(map[string]map[uint]bool)
+	for _, score := range scores {
+		if outliers[score.Metric] == nil {
+			outliers[score.Metric] = make(map[uint]bool)
+		}
+		outliers[score.Metric][score.WeatherReportID] = true
+	}
+	return outliers, nil
+}
+
+// scoreWindow scores the samples of a window, stores the scores and refreshes the reputation of the scored members.
+// It returns the outlier flag of every scored report per metric.
+func (a *Aggregator) scoreWindow(samples map[string][]Sample) (map[string]map[uint]bool, error) {
+	outliers := make(map[string]map[uint]bool)
+	scoredMembers := make(map[uint]struct{})
+	for metric, metricSamples := range samples {
+		outliers[metric] = make(map[uint]bool)
+		for _, score := range ScoreSamples(metricSamples, a.config.MinReporters, a.config.OutlierThreshold) {
+			if err := db.UpsertReportScore(a.DataBase.DB, &db.ReportScore{
+				WeatherReportID: score.ReportID,
+				Metric:          metric,
+				MembershipID:    score.MembershipID,
+				Value:           score.Value,
+				Consensus:       score.Consensus,
+				Deviation:       score.Deviation,
+				Outlier:         score.Outlier,
+			}); err != nil {
+				return nil, err
+			}
+			outliers[metric][score.ReportID] = score.Outlier
+			scoredMembers[score.MembershipID] = struct{}{}
+		}
+	}
+
+	for membershipID := range scoredMembers {
+		summary, err := db.SummarizeRecentScores(a.DataBase.DB, membershipID, a.config.ReputationWindow)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.UpdateMemberReputation(a.DataBase.DB, membershipID, Reputation(summary.Scored, summary.Outliers)); err != nil {
+			return nil, err
+		}
+	}
+	return outliers, nil
+}
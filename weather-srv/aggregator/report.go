@@ -0,0 +1,79 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValueMetric is the metric name of reports that are a plain number
+const ValueMetric = "value"
+
+// ParseReport extracts the numeric metrics of a report. A report is either a plain number,
+// stored under ValueMetric, or a JSON object whose numeric fields are metrics.
+func ParseReport(report string) map[string]float64 {
+	report = strings.TrimSpace(report)
+	if v, err := strconv.ParseFloat(report, 64); err == nil {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+		return map[string]float64{ValueMetric: v}
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(report), &fields); err != nil {
+		return nil
+	}
+	metrics := make(map[string]float64)
+	for name, field := range fields {
+		if v, ok := field.(float64); ok {
+			metrics[name] = v
+		}
+	}
+	return metrics
+}
+
+// RegionField is the report field naming the region the report was taken in
+const RegionField = "region"
+
+// ReportRegion returns the region field of a JSON object report, or "" when the report has none
+func ReportRegion(report string) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(report)), &fields); err != nil {
+		return ""
+	}
+	region, _ := fields[RegionField].(string)
+	return strings.TrimSpace(region)
+}
+
+// Stats are the aggregates of a set of values
+type Stats struct {
+	Count  int64
+	Mean   float64
+	Median float64
+	Min    float64
+	Max    float64
+}
+
+// ComputeStats returns the aggregates of values, values is sorted in place
+func ComputeStats(values []float64) Stats {
+	if len(values) == 0 {
+		return Stats{}
+	}
+	sort.Float64s(values)
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	n := len(values)
+	return Stats{
+		Count:  int64(n),
+		Mean:   sum / float64(n),
+		Median: median(values),
+		Min:    values[0],
+		Max:    values[n-1],
+	}
+}
@@ -0,0 +1,68 @@
+package aggregator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   map[string]float64
+	}{
+		{name: "plain number", report: "21.5", want: map[string]float64{ValueMetric: 21.5}},
+		{name: "padded number", report: "  -3 \n", want: map[string]float64{ValueMetric: -3}},
+		{name: "json object", report: `{"temperature": 21.5, "humidity": 40, "region": "eu", "ok": true}`, want: map[string]float64{"temperature": 21.5, "humidity": 40}},
+		{name: "json without metrics", report: `{"region": "eu"}`, want: map[string]float64{}},
+		{name: "not a number", report: "NaN"},
+		{name: "infinite", report: "+Inf"},
+		{name: "free text", report: "sunny"},
+		{name: "json array", report: "[1, 2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseReport(tt.report); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseReport(%q) = %v, want %v", tt.report, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   Stats
+	}{
+		{name: "empty", want: Stats{}},
+		{name: "single", values: []float64{4}, want: Stats{Count: 1, Mean: 4, Median: 4, Min: 4, Max: 4}},
+		{name: "odd count", values: []float64{9, 1, 5}, want: Stats{Count: 3, Mean: 5, Median: 5, Min: 1, Max: 9}},
+		{name: "even count", values: []float64{4, 1, 3, 8}, want: Stats{Count: 4, Mean: 4, Median: 3.5, Min: 1, Max: 8}},
+		{name: "negative values", values: []float64{-2, -6, 2}, want: Stats{Count: 3, Mean: -2, Median: -2, Min: -6, Max: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeStats(tt.values); got != tt.want {
+				t.Errorf("ComputeStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportRegion(t *testing.T) {
+	tests := []struct {
+		report string
+		want   string
+	}{
+		{report: `{"region": " eu-west "}`, want: "eu-west"},
+		{report: `{"region": 3}`},
+		{report: `{"temperature": 21}`},
+		{report: "21"},
+	}
+	for _, tt := range tests {
+		if got := ReportRegion(tt.report); got != tt.want {
+			t.Errorf("ReportRegion(%q) = %q, want %q", tt.report, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package aggregator
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// madScale makes the median absolute deviation consistent with the standard deviation of normal data
+	madScale = 1.4826
+	// minRelativeScale bounds the deviation scale to a share of the consensus, so unanimous reporters
+	// do not turn any rounding difference into an outlier
+	minRelativeScale = 0.01
+	// minAbsoluteScale bounds the deviation scale when the consensus is zero
+	minAbsoluteScale = 1e-6
+)
+
+// Sample is one metric value of a report
+type Sample struct {
+	ReportID     uint
+	MembershipID uint
+	Value        float64
+}
+
+// Score is the deviation of a sample from the consensus of the other reporters
+type Score struct {
+	Sample
+	Consensus float64
+	Deviation float64
+	Outlier   bool
+}
+
+// ScoreSamples scores every sample against the median of the samples of the other members, using the
+// median absolute deviation as scale. Samples without at least minReporters-1 other reporters are not scored.
+func ScoreSamples(samples []Sample, minReporters int, threshold float64) []Score {
+	var scores []Score
+	for _, s := range samples {
+		var others []float64
+		reporters := make(map[uint]struct{})
+		for _, o := range samples {
+			if o.MembershipID == s.MembershipID {
+				continue
+			}
+			others = append(others, o.Value)
+			reporters[o.MembershipID] = struct{}{}
+		}
+		if len(reporters) < minReporters-1 || len(others) == 0 {
+			continue
+		}
+
+		consensus := median(others)
+		deviations := make([]float64, len(others))
+		for i, o := range others {
+			deviations[i] = math.Abs(o - consensus)
+		}
+		scale := math.Max(madScale*median(deviations), math.Max(minRelativeScale*math.Abs(consensus), minAbsoluteScale))
+		deviation := math.Abs(s.Value-consensus) / scale
+
+		scores = append(scores, Score{
+			Sample:    s,
+			Consensus: consensus,
+			Deviation: deviation,
+			Outlier:   deviation > threshold,
+		})
+	}
+	return scores
+}
+
+// Reputation returns the share of scored values that were not outliers, members without scores have full reputation
+func Reputation(scored, outliers int64) float64 {
+	if scored == 0 {
+		return 1
+	}
+	return 1 - float64(outliers)/float64(scored)
+}
+
+// WeightedMean returns the mean of values weighted by weights, falling back to the plain mean when every weight is zero
+func WeightedMean(values, weights []float64) float64 {
+	var sum, total, plain float64
+	for i, v := range values {
+		sum += v * weights[i]
+		total += weights[i]
+		plain += v
+	}
+	if total == 0 {
+		if len(values) == 0 {
+			return 0
+		}
+		return plain / float64(len(values))
+	}
+	return sum / total
+}
+
+// median returns the median of values without modifying them
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[n/2]
+}
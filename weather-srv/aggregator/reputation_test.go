@@ -0,0 +1,113 @@
+package aggregator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoreSamples(t *testing.T) {
+	tests := []struct {
+		name         string
+		samples      []Sample
+		minReporters int
+		want         map[uint]bool // Outlier flag per scored report
+	}{
+		{
+			name:         "too few reporters",
+			samples:      []Sample{{1, 1, 20}, {2, 2, 21}},
+			minReporters: 3,
+			want:         map[uint]bool{},
+		},
+		{
+			name:         "agreeing reporters",
+			samples:      []Sample{{1, 1, 20}, {2, 2, 21}, {3, 3, 20.5}, {4, 4, 19.5}},
+			minReporters: 3,
+			want:         map[uint]bool{1: false, 2: false, 3: false, 4: false},
+		},
+		{
+			name:         "outlier",
+			samples:      []Sample{{1, 1, 20}, {2, 2, 21}, {3, 3, 20.5}, {4, 4, 80}},
+			minReporters: 3,
+			want:         map[uint]bool{1: false, 2: false, 3: false, 4: true},
+		},
+		{
+			name:         "unanimous reporters tolerate rounding",
+			samples:      []Sample{{1, 1, 20}, {2, 2, 20}, {3, 3, 20}, {4, 4, 20.1}},
+			minReporters: 3,
+			want:         map[uint]bool{1: false, 2: false, 3: false, 4: false},
+		},
+		{
+			name:         "own reports are not consensus",
+			samples:      []Sample{{1, 1, 20}, {2, 1, 20}, {3, 2, 50}},
+			minReporters: 3,
+			want:         map[uint]bool{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := ScoreSamples(tt.samples, tt.minReporters, 3.5)
+			if len(scores) != len(tt.want) {
+				t.Fatalf("scored %d samples, want %d", len(scores), len(tt.want))
+			}
+			for _, s := range scores {
+				outlier, ok := tt.want[s.ReportID]
+				if !ok {
+					t.Fatalf("report %d scored", s.ReportID)
+				}
+				if s.Outlier != outlier {
+					t.Errorf("report %d outlier = %v (deviation %.2f from %.2f), want %v", s.ReportID, s.Outlier, s.Deviation, s.Consensus, outlier)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreSamplesConsensus(t *testing.T) {
+	scores := ScoreSamples([]Sample{{1, 1, 10}, {2, 2, 20}, {3, 3, 40}}, 3, 3.5)
+	// Each sample is compared to the median of the two other members
+	want := map[uint]float64{1: 30, 2: 25, 3: 15}
+	for _, s := range scores {
+		if s.Consensus != want[s.ReportID] {
+			t.Errorf("report %d consensus = %v, want %v", s.ReportID, s.Consensus, want[s.ReportID])
+		}
+	}
+}
+
+func TestReputation(t *testing.T) {
+	tests := []struct {
+		scored, outliers int64
+		want             float64
+	}{
+		{0, 0, 1},
+		{10, 0, 1},
+		{10, 3, 0.7},
+		{4, 4, 0},
+	}
+	for _, tt := range tests {
+		if got := Reputation(tt.scored, tt.outliers); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Reputation(%d, %d) = %v, want %v", tt.scored, tt.outliers, got, tt.want)
+		}
+	}
+}
+
+func TestWeightedMean(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []float64
+		weights []float64
+		want    float64
+	}{
+		{name: "empty", want: 0},
+		{name: "equal weights", values: []float64{10, 20}, weights: []float64{1, 1}, want: 15},
+		{name: "weighted", values: []float64{10, 20}, weights: []float64{1, 3}, want: 17.5},
+		{name: "zero weight ignored", values: []float64{10, 100}, weights: []float64{1, 0}, want: 10},
+		{name: "every weight zero", values: []float64{10, 20}, weights: []float64{0, 0}, want: 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WeightedMean(tt.values, tt.weights); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("WeightedMean() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
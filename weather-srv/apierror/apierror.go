@@ -0,0 +1,93 @@
+package apierror
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Error codes shared by every route, handlers add their own more specific codes
+const (
+	CodeInvalidRequest   = "invalid_request"     // Payload, path or query params are malformed
+	CodeSchemaMismatch   = "schema_mismatch"     // Request does not match the OpenAPI document
+	CodeUnauthorized     = "unauthorized"        // Caller is not a registered member or not an admin
+	CodeNotFound         = "not_found"           // Route or resource does not exist
+	CodeMethodNotAllowed = "method_not_allowed"  // Route does not accept the method
+	CodeRateLimited      = "rate_limited"        // Reporting window of the member has not opened yet
+	CodeWindowPassed     = "window_passed"       // Reporting window of the member has closed
+	CodeInternal         = "internal_error"      // Unexpected failure, details are only logged
+	CodeDisabled         = "feature_disabled"    // Feature is turned off in the configuration
+	CodeUnavailable      = "service_unavailable" // Service is at capacity, retry after Retry-After
+)
+
+// MessageInternal is the message of every internal error, the cause is only logged
+const MessageInternal = "Internal server error"
+
+const (
+	// RequestIDHeader carries the request ID, a valid client supplied ID is kept
+	RequestIDHeader = "X-Request-ID"
+	// requestIDKey is the gin context key of the request ID
+	requestIDKey = "request_id"
+)
+
+// validRequestID restricts client supplied request IDs to short opaque tokens
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Error is the machine-readable error returned to clients
+type Error struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"request_id"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// Envelope wraps every error response body
+type Envelope struct {
+	Error Error `json:"error"`
+}
+
+// RequestIDMiddleware assigns every request an ID, echoed in the X-Request-ID response header
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the ID assigned to the request by RequestIDMiddleware
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Write writes an error envelope with the request ID, details are omitted when nil
+func Write(c *gin.Context, status int, code, message string, details interface{}) {
+	c.JSON(status, Envelope{Error: Error{
+		Code:      code,
+		Message:   message,
+		RequestID: RequestID(c),
+		Details:   details,
+	}})
+}
+
+// Abort writes an error envelope and stops the remaining handlers
+func Abort(c *gin.Context, status int, code, message string, details interface{}) {
+	Write(c, status, code, message, details)
+	c.Abort()
+}
+
+// NoRoute answers requests to unknown routes with an error envelope
+func NoRoute(c *gin.Context) {
+	Write(c, http.StatusNotFound, CodeNotFound, "Route not found", nil)
+}
+
+// NoMethod answers requests with a method the route does not accept with an error envelope
+func NoMethod(c *gin.Context) {
+	Write(c, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed", nil)
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// messageHeaderSuffix ends the first line of an EIP-4361 message, after the requesting domain
+const messageHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+// Message is a parsed Sign-In with Ethereum (EIP-4361) message
+type Message struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseMessage parses an EIP-4361 message, the address must be EIP-55 checksummed
+func ParseMessage(raw string) (*Message, error) {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	if len(lines) < 2 || !strings.HasSuffix(lines[0], messageHeaderSuffix) {
+		return nil, errors.New("missing sign-in header")
+	}
+
+	m := &Message{Domain: strings.TrimSuffix(lines[0], messageHeaderSuffix)}
+	if m.Domain == "" {
+		return nil, errors.New("missing domain")
+	}
+	if !common.IsHexAddress(lines[1]) || common.HexToAddress(lines[1]).Hex() != lines[1] {
+		return nil, fmt.Errorf("address %q is not EIP-55 checksummed", lines[1])
+	}
+	m.Address = lines[1]
+
+	// An optional statement sits between the address and the fields, surrounded by empty lines
+	i := 2
+	var statement []string
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "URI: "); i++ {
+		if lines[i] != "" {
+			statement = append(statement, lines[i])
+		}
+	}
+	m.Statement = strings.Join(statement, "\n")
+
+	var err error
+	inResources := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if inResources {
+			if strings.HasPrefix(line, "- ") {
+				m.Resources = append(m.Resources, strings.TrimPrefix(line, "- "))
+				continue
+			}
+			inResources = false
+		}
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			inResources = true
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		switch key {
+		case "URI":
+			m.URI = value
+		case "Version":
+			m.Version = value
+		case "Chain ID":
+			if m.ChainID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid chain id %q", value)
+			}
+		case "Nonce":
+			m.Nonce = value
+		case "Issued At":
+			if m.IssuedAt, err = time.Parse(time.RFC3339, value); err != nil {
+				return nil, fmt.Errorf("invalid issued at %q", value)
+			}
+		case "Expiration Time":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expiration time %q", value)
+			}
+			m.ExpirationTime = &t
+		case "Not Before":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid not before %q", value)
+			}
+			m.NotBefore = &t
+		case "Request ID":
+			m.RequestID = value
+		default:
+			return nil, fmt.Errorf("unknown field %q", key)
+		}
+	}
+
+	switch {
+	case m.URI == "":
+		return nil, errors.New("missing URI")
+	case m.Version != "1":
+		return nil, fmt.Errorf("unsupported version %q", m.Version)
+	case m.ChainID == 0:
+		return nil, errors.New("missing chain id")
+	case len(m.Nonce) < 8:
+		return nil, errors.New("nonce must have at least 8 characters")
+	case m.IssuedAt.IsZero():
+		return nil, errors.New("missing issued at")
+	}
+	return m, nil
+}
+
+// ValidAt checks that the message may be used at now
+func (m *Message) ValidAt(now time.Time) error {
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return errors.New("message expired")
+	}
+	if m.NotBefore != nil && now.Before(*m.NotBefore) {
+		return errors.New("message not valid yet")
+	}
+	return nil
+}
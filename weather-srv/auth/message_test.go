@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAddress = "0x36F3e6b9eFB8E4874a4B43965eD73E077BCa57c6"
+
+// signInMessage joins the lines of a message after the header and address
+func signInMessage(lines ...string) string {
+	return strings.Join(append([]string{"localhost:8080" + messageHeaderSuffix, testAddress}, lines...), "\n")
+}
+
+func TestParseMessage(t *testing.T) {
+	fields := []string{"URI: http://localhost:8080", "Version: 1", "Chain ID: 421613", "Nonce: abcdef123456", "Issued At: 2023-10-01T12:00:00Z"}
+	full := signInMessage(append([]string{"", "Sign in to the weather service.", ""}, append(fields,
+		"Expiration Time: 2023-10-01T12:05:00Z",
+		"Not Before: 2023-10-01T11:59:00Z",
+		"Request ID: req-1",
+		"Resources:",
+		"- https://example.com/a",
+		"- https://example.com/b",
+	)...)...)
+
+	message, err := ParseMessage(full)
+	if err != nil {
+		t.Fatalf("ParseMessage(full) error = %v", err)
+	}
+	if message.Domain != "localhost:8080" || message.Address != testAddress || message.Statement != "Sign in to the weather service." {
+		t.Errorf("header = %q %q %q", message.Domain, message.Address, message.Statement)
+	}
+	if message.URI != "http://localhost:8080" || message.Version != "1" || message.ChainID != 421613 || message.Nonce != "abcdef123456" || message.RequestID != "req-1" {
+		t.Errorf("fields = %+v", message)
+	}
+	if !message.IssuedAt.Equal(time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("IssuedAt = %v", message.IssuedAt)
+	}
+	if message.ExpirationTime == nil || message.NotBefore == nil {
+		t.Fatalf("ExpirationTime = %v, NotBefore = %v", message.ExpirationTime, message.NotBefore)
+	}
+	if len(message.Resources) != 2 || message.Resources[1] != "https://example.com/b" {
+		t.Errorf("Resources = %v", message.Resources)
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string // Substring of the error, empty when the message is valid
+	}{
+		{name: "without statement", raw: signInMessage(append([]string{""}, fields...)...)},
+		{name: "crlf line endings", raw: strings.ReplaceAll(signInMessage(append([]string{""}, fields...)...), "\n", "\r\n")},
+		{name: "missing header", raw: strings.Join(append([]string{testAddress}, fields...), "\n"), wantErr: "header"},
+		{name: "missing domain", raw: strings.Replace(signInMessage(fields...), "localhost:8080", "", 1), wantErr: "domain"},
+		{name: "lowercase address", raw: strings.Replace(signInMessage(fields...), testAddress, strings.ToLower(testAddress), 1), wantErr: "checksummed"},
+		{name: "missing uri", raw: signInMessage(fields[1:]...), wantErr: "URI"},
+		{name: "unsupported version", raw: strings.Replace(signInMessage(fields...), "Version: 1", "Version: 2", 1), wantErr: "version"},
+		{name: "invalid chain id", raw: strings.Replace(signInMessage(fields...), "421613", "arbitrum", 1), wantErr: "chain id"},
+		{name: "short nonce", raw: strings.Replace(signInMessage(fields...), "abcdef123456", "abc", 1), wantErr: "nonce"},
+		{name: "invalid issued at", raw: strings.Replace(signInMessage(fields...), "2023-10-01T12:00:00Z", "yesterday", 1), wantErr: "issued at"},
+		{name: "missing issued at", raw: signInMessage(fields[:4]...), wantErr: "issued at"},
+		{name: "invalid expiration time", raw: signInMessage(append(fields, "Expiration Time: soon")...), wantErr: "expiration"},
+		{name: "unknown field", raw: signInMessage(append(fields, "Color: blue")...), wantErr: "unknown field"},
+		{name: "malformed line", raw: signInMessage(append(fields, "no separator")...), wantErr: "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMessage(tt.raw)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ParseMessage() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ParseMessage() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageValidAt(t *testing.T) {
+	start := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(5 * time.Minute)
+	tests := []struct {
+		name      string
+		notBefore *time.Time
+		expires   *time.Time
+		now       time.Time
+		wantErr   bool
+	}{
+		{name: "no bounds", now: start},
+		{name: "within bounds", notBefore: &start, expires: &end, now: start.Add(time.Minute)},
+		{name: "at not before", notBefore: &start, now: start},
+		{name: "before not before", notBefore: &start, now: start.Add(-time.Second), wantErr: true},
+		{name: "at expiration", expires: &end, now: end, wantErr: true},
+		{name: "after expiration", expires: &end, now: end.Add(time.Second), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Message{NotBefore: tt.notBefore, ExpirationTime: tt.expires}
+			if err := m.ValidAt(tt.now); (err != nil) != tt.wantErr {
+				t.Errorf("ValidAt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Session token defaults used when the configuration leaves them unset
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 7 * 24 * time.Hour
+	DefaultNonceTTL   = 5 * time.Minute
+)
+
+// accessTokenType is the typ claim of access tokens, so that no other token signed with the secret is accepted
+const accessTokenType = "access"
+
+// ErrTokenExpired is returned for access tokens that were valid but have expired
+var ErrTokenExpired = errors.New("token expired")
+
+// Config Sign-In with Ethereum session configuration
+type Config struct {
+	Domain      string        // Domain expected in sign-in messages, also the issuer of access tokens
+	TokenSecret string        // HMAC secret signing access tokens, sessions are disabled when it or Domain is empty
+	AccessTTL   time.Duration // Lifetime of access tokens
+	RefreshTTL  time.Duration // Lifetime of refresh tokens
+	NonceTTL    time.Duration // Lifetime of sign-in nonces
+}
+
+// Claims are the claims of an access token, the subject is the checksummed member address
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID uint   `json:"sid"`
+	Type      string `json:"typ"`
+}
+
+// TokenIssuer issues and verifies short-lived HS256 access tokens and opaque refresh tokens
+type TokenIssuer struct {
+	config Config
+	secret []byte
+}
+
+// NewTokenIssuer creates a new TokenIssuer instance, it returns nil when no domain or token secret is configured
+func NewTokenIssuer(cfg Config) *TokenIssuer {
+	if cfg.Domain == "" || cfg.TokenSecret == "" {
+		return nil
+	}
+	if cfg.AccessTTL <= 0 {
+		cfg.AccessTTL = DefaultAccessTTL
+	}
+	if cfg.RefreshTTL <= 0 {
+		cfg.RefreshTTL = DefaultRefreshTTL
+	}
+	if cfg.NonceTTL <= 0 {
+		cfg.NonceTTL = DefaultNonceTTL
+	}
+	return &TokenIssuer{config: cfg, secret: []byte(cfg.TokenSecret)}
+}
+
+// Config returns the configuration with defaults applied
+func (t *TokenIssuer) Config() Config {
+	return t.config
+}
+
+// IssueAccessToken signs an access token for address within the session
+func (t *TokenIssuer) IssueAccessToken(address string, sessionID uint, now time.Time) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.config.Domain,
+			Subject:   address,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.config.AccessTTL)),
+		},
+		SessionID: sessionID,
+		Type:      accessTokenType,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+}
+
+// VerifyAccessToken checks the signature, issuer, type and expiry of an access token and returns its claims
+func (t *TokenIssuer) VerifyAccessToken(token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(tok *jwt.Token) (interface{}, error) {
+		if tok.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", tok.Header["alg"])
+		}
+		return t.secret, nil
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return nil, ErrTokenExpired
+		}
+		return nil, err
+	}
+	if claims.Type != accessTokenType || !claims.VerifyIssuer(t.config.Domain, true) {
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
+
+// NewRefreshToken returns a random refresh token and the hash stored in its place
+func NewRefreshToken() (string, string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the hex SHA-256 of a refresh token, only hashes are stored
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// NewNonce returns a random alphanumeric sign-in nonce
+func NewNonce() (string, error) {
+	return randomHex(16)
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
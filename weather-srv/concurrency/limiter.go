@@ -0,0 +1,106 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultMaxInFlight  = 10
+	DefaultQueueTimeout = 500 * time.Millisecond
+	DefaultRetryAfter   = time.Second
+)
+
+var (
+	// ErrQueueFull is returned when every slot is taken and the queue is full
+	ErrQueueFull = errors.New("concurrency: queue full")
+	// ErrQueueTimeout is returned when no slot was freed within the queue timeout
+	ErrQueueTimeout = errors.New("concurrency: queue timeout")
+)
+
+// Config concurrency limit configuration, a zero queue size sheds requests as soon as every slot is taken
+type Config struct {
+	MaxInFlight  int           // Requests processed at the same time
+	MaxQueue     int           // Requests waiting for a slot
+	QueueTimeout time.Duration // How long a request waits for a slot
+	RetryAfter   time.Duration // How long rejected clients are asked to wait before retrying
+}
+
+// Stats is a snapshot of the usage of a Limiter
+type Stats struct {
+	Capacity int `json:"capacity"`
+	InFlight int `json:"in_flight"`
+	Queued   int `json:"queued"`
+}
+
+// Limiter bounds the number of requests processed at the same time, requests beyond it wait in a bounded queue
+type Limiter struct {
+	config Config
+	slots  chan struct{}
+	queued int64 // Requests waiting for a slot, updated atomically
+}
+
+// NewLimiter creates a new Limiter instance
+func NewLimiter(cfg Config) *Limiter {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = DefaultMaxInFlight
+	}
+	if cfg.MaxQueue < 0 {
+		cfg.MaxQueue = 0
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = DefaultQueueTimeout
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = DefaultRetryAfter
+	}
+	return &Limiter{config: cfg, slots: make(chan struct{}, cfg.MaxInFlight)}
+}
+
+// Config returns the configuration with defaults applied
+func (l *Limiter) Config() Config {
+	return l.config
+}
+
+// Acquire takes a slot, waiting in the queue up to the queue timeout or until ctx is done. The returned function
+// releases the slot and must be called once the request is processed.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	if atomic.AddInt64(&l.queued, 1) > int64(l.config.MaxQueue) {
+		atomic.AddInt64(&l.queued, -1)
+		return nil, ErrQueueFull
+	}
+	defer atomic.AddInt64(&l.queued, -1)
+
+	timer := time.NewTimer(l.config.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-timer.C:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
+
+// Stats returns the current usage of the limiter
+func (l *Limiter) Stats() Stats {
+	return Stats{
+		Capacity: l.config.MaxInFlight,
+		InFlight: len(l.slots),
+		Queued:   int(atomic.LoadInt64(&l.queued)),
+	}
+}
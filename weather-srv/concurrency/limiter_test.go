@@ -0,0 +1,144 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterAcquire(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		held     int           // Slots taken before the acquisition under test
+		release  bool          // Whether a held slot is released while the acquisition waits
+		cancel   bool          // Whether the context of the acquisition is cancelled while it waits
+		wantErr  error         // Expected error of the acquisition, nil when it gets a slot
+		maxWait  time.Duration // Upper bound of the time the acquisition may take
+		wantPeak int           // Expected queued count while the acquisition waits
+	}{
+		{
+			name:    "free slot",
+			config:  Config{MaxInFlight: 2, MaxQueue: 0, QueueTimeout: time.Second},
+			held:    1,
+			maxWait: 50 * time.Millisecond,
+		},
+		{
+			name:    "no queue sheds at once",
+			config:  Config{MaxInFlight: 1, MaxQueue: 0, QueueTimeout: time.Second},
+			held:    1,
+			wantErr: ErrQueueFull,
+			maxWait: 50 * time.Millisecond,
+		},
+		{
+			name:     "queued until timeout",
+			config:   Config{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: 50 * time.Millisecond},
+			held:     1,
+			wantErr:  ErrQueueTimeout,
+			maxWait:  time.Second,
+			wantPeak: 1,
+		},
+		{
+			name:     "queued until a slot is released",
+			config:   Config{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: time.Second},
+			held:     1,
+			release:  true,
+			maxWait:  500 * time.Millisecond,
+			wantPeak: 1,
+		},
+		{
+			name:     "queued until the context is done",
+			config:   Config{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: time.Second},
+			held:     1,
+			cancel:   true,
+			wantErr:  context.Canceled,
+			maxWait:  500 * time.Millisecond,
+			wantPeak: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.config)
+			var releases []func()
+			for i := 0; i < tt.held; i++ {
+				release, err := l.Acquire(context.Background())
+				if err != nil {
+					t.Fatalf("taking slot %d: %v", i, err)
+				}
+				releases = append(releases, release)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			type result struct {
+				release func()
+				err     error
+			}
+			done := make(chan result, 1)
+			start := time.Now()
+			go func() {
+				release, err := l.Acquire(ctx)
+				done <- result{release, err}
+			}()
+
+			if tt.release || tt.cancel {
+				waitQueued(t, l, tt.wantPeak)
+				if tt.release {
+					releases[0]()
+					releases = releases[1:]
+				} else {
+					cancel()
+				}
+			}
+
+			var res result
+			select {
+			case res = <-done:
+			case <-time.After(tt.maxWait):
+				t.Fatalf("Acquire() did not return within %v", tt.maxWait)
+			}
+			if !errors.Is(res.err, tt.wantErr) {
+				t.Fatalf("Acquire() error = %v, want %v", res.err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				res.release()
+			}
+			if time.Since(start) > tt.maxWait {
+				t.Fatalf("Acquire() took %v, want at most %v", time.Since(start), tt.maxWait)
+			}
+			if queued := l.Stats().Queued; queued != 0 {
+				t.Fatalf("Stats().Queued = %d after the acquisition returned, want 0", queued)
+			}
+			for _, release := range releases {
+				release()
+			}
+			if inFlight := l.Stats().InFlight; inFlight != 0 {
+				t.Fatalf("Stats().InFlight = %d after every release, want 0", inFlight)
+			}
+		})
+	}
+}
+
+// waitQueued waits until want acquisitions are queued
+func waitQueued(t *testing.T, l *Limiter, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for l.Stats().Queued != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats().Queued = %d, want %d", l.Stats().Queued, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewLimiterDefaults(t *testing.T) {
+	l := NewLimiter(Config{MaxQueue: -1})
+	want := Config{MaxInFlight: DefaultMaxInFlight, MaxQueue: 0, QueueTimeout: DefaultQueueTimeout, RetryAfter: DefaultRetryAfter}
+	if got := l.Config(); got != want {
+		t.Fatalf("Config() = %+v, want %+v", got, want)
+	}
+	if capacity := l.Stats().Capacity; capacity != DefaultMaxInFlight {
+		t.Fatalf("Stats().Capacity = %d, want %d", capacity, DefaultMaxInFlight)
+	}
+}
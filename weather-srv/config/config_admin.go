@@ -0,0 +1,13 @@
+package config
+
+// AdminConfig admin API configuration struct
+type AdminConfig struct {
+	APIKey string // Key expected in the X-Admin-Key header, admin endpoints are disabled when empty
+}
+
+// ReadAdminConfig reads admin API params from config.json, admin.api_key can be set with the ADMIN_API_KEY env variable
+func (v *viperConfig) ReadAdminConfig() AdminConfig {
+	return AdminConfig{
+		APIKey: v.GetString("admin.api_key"),
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// AggregationConfig observation aggregation configuration struct
+type AggregationConfig struct {
+	WindowSizes      []time.Duration
+	Interval         time.Duration
+	BatchSize        int
+	ScoreWindow      time.Duration
+	OutlierThreshold float64
+	MinReporters     int
+	ReputationWindow int
+}
+
+// ReadAggregationConfig reads observation aggregation params from config.json, falling back to defaults
+func (v *viperConfig) ReadAggregationConfig() AggregationConfig {
+	windows := []int{300, 3600}
+	if viper.IsSet("aggregation.window_seconds") {
+		windows = viper.GetIntSlice("aggregation.window_seconds")
+	}
+	sizes := make([]time.Duration, 0, len(windows))
+	for _, w := range windows {
+		if w > 0 {
+			sizes = append(sizes, time.Duration(w)*time.Second)
+		}
+	}
+
+	// Reports are scored in the smallest window unless configured otherwise
+	var scoreWindow time.Duration
+	for _, size := range sizes {
+		if scoreWindow == 0 || size < scoreWindow {
+			scoreWindow = size
+		}
+	}
+	if viper.IsSet("aggregation.score_window_seconds") {
+		scoreWindow = time.Duration(viper.GetInt64("aggregation.score_window_seconds")) * time.Second
+	}
+
+	outlierThreshold := 3.5
+	if viper.IsSet("aggregation.outlier_threshold") {
+		outlierThreshold = viper.GetFloat64("aggregation.outlier_threshold")
+	}
+
+	return AggregationConfig{
+		WindowSizes:      sizes,
+		Interval:         time.Duration(v.getInt64OrDefault("aggregation.interval_seconds", 10)) * time.Second,
+		BatchSize:        int(v.getInt64OrDefault("aggregation.batch_size", 1000)),
+		ScoreWindow:      scoreWindow,
+		OutlierThreshold: outlierThreshold,
+		MinReporters:     int(v.getInt64OrDefault("aggregation.min_reporters", 3)),
+		ReputationWindow: int(v.getInt64OrDefault("aggregation.reputation_window", 100)),
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// AuthConfig Sign-In with Ethereum session configuration struct
+type AuthConfig struct {
+	Domain      string // Domain sign-in messages must be made for
+	TokenSecret string // HMAC secret of access tokens, sessions are disabled when it or the domain is empty
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration
+	NonceTTL    time.Duration
+}
+
+// ReadAuthConfig reads session params from config.json, auth.token_secret can be set with the AUTH_TOKEN_SECRET env variable
+func (v *viperConfig) ReadAuthConfig() AuthConfig {
+	return AuthConfig{
+		Domain:      v.GetString("auth.domain"),
+		TokenSecret: v.GetString("auth.token_secret"),
+		AccessTTL:   time.Duration(v.getInt64OrDefault("auth.access_ttl_seconds", 900)) * time.Second,
+		RefreshTTL:  time.Duration(v.getInt64OrDefault("auth.refresh_ttl_seconds", 604800)) * time.Second,
+		NonceTTL:    time.Duration(v.getInt64OrDefault("auth.nonce_ttl_seconds", 300)) * time.Second,
+	}
+}
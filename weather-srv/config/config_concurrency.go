@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+// ConcurrencyConfig report concurrency limit configuration struct
+type ConcurrencyConfig struct {
+	MaxInFlight  int           // Reports processed at the same time
+	MaxQueue     int           // Reports waiting for a slot
+	QueueTimeout time.Duration // How long a report waits for a slot before it is rejected with 503
+	RetryAfter   time.Duration // Retry-After sent with the 503
+}
+
+// ReadConcurrencyConfig reads report concurrency limit params from config.json, falling back to defaults
+func (v *viperConfig) ReadConcurrencyConfig() ConcurrencyConfig {
+	return ConcurrencyConfig{
+		MaxInFlight:  int(v.getInt64OrDefault("concurrency.max_in_flight", 10)),
+		MaxQueue:     int(v.getInt64OrDefault("concurrency.max_queue", 50)),
+		QueueTimeout: time.Duration(v.getInt64OrDefault("concurrency.queue_timeout_ms", 500)) * time.Millisecond,
+		RetryAfter:   time.Duration(v.getInt64OrDefault("concurrency.retry_after_seconds", 1)) * time.Second,
+	}
+}
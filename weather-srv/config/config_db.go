@@ -1,6 +1,9 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // PostgresDbConfig is a struct holding the Postgres database connection configuration
 type PostgresDbConfig struct {
@@ -32,3 +35,21 @@ func (v *viperConfig) ReadDBConfig() PostgresDbConfig {
 		Password: v.GetString("storage.password"),
 	}
 }
+
+// DBPoolConfig database connection pool configuration struct
+type DBPoolConfig struct {
+	MaxOpenConns    int           // Connections open at the same time
+	MaxIdleConns    int           // Connections kept open while idle
+	ConnMaxLifetime time.Duration // Age at which a connection is closed
+	AcquireTimeout  time.Duration // How long a request waits for a connection when it has no deadline of its own
+}
+
+// ReadDBPoolConfig reads database connection pool params from config.json, falling back to defaults
+func (v *viperConfig) ReadDBPoolConfig() DBPoolConfig {
+	return DBPoolConfig{
+		MaxOpenConns:    int(v.getInt64OrDefault("storage.max_open_conns", 20)),
+		MaxIdleConns:    int(v.getInt64OrDefault("storage.max_idle_conns", 10)),
+		ConnMaxLifetime: time.Duration(v.getInt64OrDefault("storage.conn_max_lifetime_seconds", 1800)) * time.Second,
+		AcquireTimeout:  time.Duration(v.getInt64OrDefault("storage.acquire_timeout_ms", 5000)) * time.Millisecond,
+	}
+}
@@ -0,0 +1,11 @@
+package config
+
+import "fmt"
+
+// ReadGRPCConfig reads gRPC server params from config.json, the gRPC server is disabled when grpc.port is empty
+func (v *viperConfig) ReadGRPCConfig() string {
+	if v.GetString("grpc.port") == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", v.GetString("grpc.host"), v.GetString("grpc.port"))
+}
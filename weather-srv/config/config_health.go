@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// HealthConfig readiness check configuration struct
+type HealthConfig struct {
+	Timeout    time.Duration // Time each readiness check may take
+	MaxHeadLag uint64        // Blocks the watcher may be behind the chain head while ready
+}
+
+// ReadHealthConfig reads readiness check params from config.json, falling back to defaults
+func (v *viperConfig) ReadHealthConfig() HealthConfig {
+	return HealthConfig{
+		Timeout:    time.Duration(v.getInt64OrDefault("health.timeout_seconds", 2)) * time.Second,
+		MaxHeadLag: uint64(v.getInt64OrDefault("health.max_head_lag_blocks", 500)),
+	}
+}
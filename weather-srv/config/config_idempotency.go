@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// IdempotencyConfig idempotency key configuration struct
+type IdempotencyConfig struct {
+	TTL time.Duration // How long the response of an idempotency key is replayed
+}
+
+// ReadIdempotencyConfig reads idempotency key params from config.json, falling back to defaults
+func (v *viperConfig) ReadIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		TTL: time.Duration(v.getInt64OrDefault("idempotency.ttl_seconds", 86400)) * time.Second,
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+import "github.com/spf13/viper"
+
+// LoggerConfig logging configuration struct
+type LoggerConfig struct {
+	Level  string // logrus level name, info when empty
+	Format string // text or json, text when empty
+}
+
+// ReadLoggerConfig reads the logger level and format from config.json
+func (v *viperConfig) ReadLoggerConfig() LoggerConfig {
+	return LoggerConfig{
+		Level:  viper.GetString("logger-level"),
+		Format: viper.GetString("logger-format"),
+	}
+}
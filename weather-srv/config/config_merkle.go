@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// MerkleConfig Merkle commitment configuration struct
+type MerkleConfig struct {
+	EpochLength time.Duration
+	Grace       time.Duration
+	Interval    time.Duration
+}
+
+// ReadMerkleConfig reads Merkle commitment params from config.json, falling back to defaults
+func (v *viperConfig) ReadMerkleConfig() MerkleConfig {
+	return MerkleConfig{
+		EpochLength: time.Duration(v.getInt64OrDefault("merkle.epoch_seconds", 3600)) * time.Second,
+		Grace:       time.Duration(v.getInt64OrDefault("merkle.grace_seconds", 30)) * time.Second,
+		Interval:    time.Duration(v.getInt64OrDefault("merkle.interval_seconds", 30)) * time.Second,
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// ShutdownConfig graceful shutdown configuration struct
+type ShutdownConfig struct {
+	HTTPDrainTimeout time.Duration // Time in-flight HTTP requests have to finish
+	GRPCDrainTimeout time.Duration // Time in-flight gRPC calls have to finish
+	StopTimeout      time.Duration // Time the watcher, background jobs and database each have to stop
+}
+
+// ReadShutdownConfig reads graceful shutdown params from config.json, falling back to defaults
+func (v *viperConfig) ReadShutdownConfig() ShutdownConfig {
+	return ShutdownConfig{
+		HTTPDrainTimeout: time.Duration(v.getInt64OrDefault("shutdown.http_drain_seconds", 15)) * time.Second,
+		GRPCDrainTimeout: time.Duration(v.getInt64OrDefault("shutdown.grpc_drain_seconds", 10)) * time.Second,
+		StopTimeout:      time.Duration(v.getInt64OrDefault("shutdown.stop_seconds", 10)) * time.Second,
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// StreamConfig report stream sequencing configuration struct
+type StreamConfig struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// ReadStreamConfig reads report stream sequencing params from config.json, falling back to defaults
+func (v *viperConfig) ReadStreamConfig() StreamConfig {
+	return StreamConfig{
+		Interval:  time.Duration(v.getInt64OrDefault("stream.interval_ms", 1000)) * time.Millisecond,
+		BatchSize: int(v.getInt64OrDefault("stream.batch_size", 500)),
+	}
+}
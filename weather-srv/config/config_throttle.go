@@ -0,0 +1,39 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ThrottleConfig pre-authentication throttling configuration struct
+type ThrottleConfig struct {
+	IPRate         float64       // Requests per second per client IP, 0 disables the per-IP buckets
+	IPBurst        int           // Burst per client IP
+	SubnetRate     float64       // Requests per second per client subnet, 0 disables the per-subnet buckets
+	SubnetBurst    int           // Burst per client subnet
+	IPv4Prefix     int           // Prefix length of IPv4 subnets
+	IPv6Prefix     int           // Prefix length of IPv6 subnets
+	BanThreshold   int           // Invalid signatures within BanWindow banning a client IP, 0 disables bans
+	BanWindow      time.Duration // Window counting invalid signatures
+	BanDuration    time.Duration // How long a client IP stays banned
+	MaxBodyBytes   int64         // Maximum request body size
+	TrustedProxies []string      // Proxies whose X-Forwarded-For header is trusted for the client IP, none when empty
+}
+
+// ReadThrottleConfig reads pre-authentication throttling params from config.json, falling back to defaults
+func (v *viperConfig) ReadThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		IPRate:         v.getFloat64OrDefault("throttle.ip_rate", 5),
+		IPBurst:        int(v.getInt64OrDefault("throttle.ip_burst", 20)),
+		SubnetRate:     v.getFloat64OrDefault("throttle.subnet_rate", 50),
+		SubnetBurst:    int(v.getInt64OrDefault("throttle.subnet_burst", 200)),
+		IPv4Prefix:     int(v.getInt64OrDefault("throttle.ipv4_prefix", 24)),
+		IPv6Prefix:     int(v.getInt64OrDefault("throttle.ipv6_prefix", 64)),
+		BanThreshold:   int(v.getInt64OrDefault("throttle.ban_threshold", 20)),
+		BanWindow:      time.Duration(v.getInt64OrDefault("throttle.ban_window_seconds", 600)) * time.Second,
+		BanDuration:    time.Duration(v.getInt64OrDefault("throttle.ban_seconds", 900)) * time.Second,
+		MaxBodyBytes:   v.getInt64OrDefault("throttle.max_body_bytes", 1<<20),
+		TrustedProxies: viper.GetStringSlice("throttle.trusted_proxies"),
+	}
+}
@@ -0,0 +1,21 @@
+package config
+
+import "github.com/spf13/viper"
+
+// TracingConfig OTLP tracing configuration struct
+type TracingConfig struct {
+	Endpoint    string  // host:port of the OTLP gRPC collector, tracing is disabled when empty
+	Insecure    bool    // Whether the collector is reached without TLS
+	ServiceName string  // service.name of the exported spans
+	SampleRatio float64 // Fraction of new traces that are sampled
+}
+
+// ReadTracingConfig reads OTLP tracing params from config.json, falling back to defaults
+func (v *viperConfig) ReadTracingConfig() TracingConfig {
+	return TracingConfig{
+		Endpoint:    viper.GetString("tracing.endpoint"),
+		Insecure:    viper.GetBool("tracing.insecure"),
+		ServiceName: viper.GetString("tracing.service_name"),
+		SampleRatio: v.getFloat64OrDefault("tracing.sample_ratio", 1),
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// WebhookConfig webhook delivery configuration struct
+type WebhookConfig struct {
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// ReadWebhookConfig reads webhook delivery params from config.json, falling back to defaults
+func (v *viperConfig) ReadWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:  int(v.getInt64OrDefault("webhooks.max_attempts", 8)),
+		BaseBackoff:  time.Duration(v.getInt64OrDefault("webhooks.base_backoff_seconds", 5)) * time.Second,
+		MaxBackoff:   time.Duration(v.getInt64OrDefault("webhooks.max_backoff_seconds", 3600)) * time.Second,
+		PollInterval: time.Duration(v.getInt64OrDefault("webhooks.poll_interval_seconds", 2)) * time.Second,
+		Timeout:      time.Duration(v.getInt64OrDefault("webhooks.timeout_seconds", 10)) * time.Second,
+	}
+}
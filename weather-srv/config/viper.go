@@ -10,8 +10,23 @@ import (
 // Config ...
 type Config interface {
 	ReadServiceConfig() string
+	ReadGRPCConfig() string
 	ReadDBConfig() PostgresDbConfig
+	ReadDBPoolConfig() DBPoolConfig
 	ReadWorkersConfig() WorkerConfig
+	ReadWebhookConfig() WebhookConfig
+	ReadAggregationConfig() AggregationConfig
+	ReadStreamConfig() StreamConfig
+	ReadMerkleConfig() MerkleConfig
+	ReadAdminConfig() AdminConfig
+	ReadAuthConfig() AuthConfig
+	ReadIdempotencyConfig() IdempotencyConfig
+	ReadThrottleConfig() ThrottleConfig
+	ReadHealthConfig() HealthConfig
+	ReadTracingConfig() TracingConfig
+	ReadLoggerConfig() LoggerConfig
+	ReadShutdownConfig() ShutdownConfig
+	ReadConcurrencyConfig() ConcurrencyConfig
 	GetString(key string) string
 	GetStringMap(key string) map[string]string
 	GetInt64(key string) int64
@@ -50,6 +65,14 @@ func (v *viperConfig) GetInt64(key string) int64 {
 	return viper.GetInt64(key)
 }
 
+// getInt64OrDefault returns the int64 value of key, or def when the key is not set
+func (v *viperConfig) getInt64OrDefault(key string, def int64) int64 {
+	if !viper.IsSet(key) {
+		return def
+	}
+	return viper.GetInt64(key)
+}
+
 func (v *viperConfig) GetBool(key string) bool {
 	return viper.GetBool(key)
 }
@@ -58,6 +81,14 @@ func (v *viperConfig) GetFloat64(key string) float64 {
 	return viper.GetFloat64(key)
 }
 
+// getFloat64OrDefault returns the float64 value of key, or def when the key is not set
+func (v *viperConfig) getFloat64OrDefault(key string, def float64) float64 {
+	if !viper.IsSet(key) {
+		return def
+	}
+	return viper.GetFloat64(key)
+}
+
 func (v *viperConfig) GetStringMap(key string) map[string]string {
 	return viper.GetStringMapString(key)
 }
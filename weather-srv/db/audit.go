@@ -0,0 +1,28 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+// Admin audit log actions
+const (
+	AuditMemberSuspended        = "member.suspended"
+	AuditMemberSuspensionLifted = "member.suspension_lifted"
+	AuditMemberOverridesUpdated = "member.overrides_updated"
+)
+
+// CreateAdminAuditLog records an admin action in the database.
+func CreateAdminAuditLog(DB *gorm.DB, entry *AdminAuditLog) error {
+	return DB.Create(entry).Error
+}
+
+// FindAdminAuditLogs returns a page of admin actions, newest first, an empty address matches every member.
+func FindAdminAuditLogs(DB *gorm.DB, address string, limit, offset int) ([]AdminAuditLog, error) {
+	var entries []AdminAuditLog
+	query := DB.Model(&AdminAuditLog{})
+	if address != "" {
+		query = query.Where("address = ?", address)
+	}
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+// CreateAuthNonce stores a sign-in nonce and deletes the nonces that expired before now.
+func CreateAuthNonce(DB *gorm.DB, nonce *AuthNonce, now int64) error {
+	if err := DB.Where("expires_at <= ?", now).Delete(&AuthNonce{}).Error; err != nil {
+		return err
+	}
+	return DB.Create(nonce).Error
+}
+
+// ConsumeAuthNonce marks an unused, unexpired nonce as used, it returns false when the nonce cannot be used.
+func ConsumeAuthNonce(DB *gorm.DB, nonce string, now int64) (bool, error) {
+	result := DB.Model(&AuthNonce{}).
+		Where("nonce = ? AND used = ? AND expires_at > ?", nonce, false, now).
+		Update("used", true)
+	return result.RowsAffected == 1, result.Error
+}
+
+// CreateAuthSession creates a new sign-in session in the database.
+func CreateAuthSession(DB *gorm.DB, session *AuthSession) error {
+	return DB.Create(session).Error
+}
+
+// FindLiveAuthSession returns the unrevoked, unexpired session holding refreshTokenHash.
+func FindLiveAuthSession(DB *gorm.DB, refreshTokenHash string, now int64) (*AuthSession, error) {
+	var session AuthSession
+	if err := DB.Where("refresh_token_hash = ? AND revoked = ? AND expires_at > ?", refreshTokenHash, false, now).
+		First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateAuthSession replaces the refresh token of the live session holding refreshTokenHash. The session keeps the
+// expiry set at sign-in, refreshing never extends it.
+// It returns gorm.ErrRecordNotFound when no live session holds the token.
+func RotateAuthSession(DB *gorm.DB, refreshTokenHash, newRefreshTokenHash string, now int64) (*AuthSession, error) {
+	var session AuthSession
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("refresh_token_hash = ? AND revoked = ? AND expires_at > ?", refreshTokenHash, false, now).
+			First(&session).Error; err != nil {
+			return err
+		}
+		// Only the first concurrent refresh with the same token wins
+		result := tx.Model(&AuthSession{}).
+			Where("id = ? AND refresh_token_hash = ?", session.ID, refreshTokenHash).
+			Update("refresh_token_hash", newRefreshTokenHash)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	session.RefreshTokenHash = newRefreshTokenHash
+	return &session, nil
+}
+
+// RevokeAuthSession revokes the session holding refreshTokenHash.
+func RevokeAuthSession(DB *gorm.DB, refreshTokenHash string) error {
+	return DB.Model(&AuthSession{}).Where("refresh_token_hash = ?", refreshTokenHash).Update("revoked", true).Error
+}
@@ -1,102 +1,157 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"errors"
-	"sync"
+	"fmt"
+	"sync/atomic"
+	"time"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// ConnectionPool represents a pool of database connections
-type ConnectionPool struct {
-	maxSize      int
-	pool         chan *gorm.DB
-	connectionMu sync.RWMutex
+// Defaults used when the pool configuration leaves them unset
+const (
+	DefaultMaxOpenConns    = 20
+	DefaultMaxIdleConns    = 10
+	DefaultConnMaxLifetime = 30 * time.Minute
+	DefaultAcquireTimeout  = 5 * time.Second
+)
+
+var (
+	// ErrPoolClosed is returned when acquiring a connection from a closed pool
+	ErrPoolClosed = errors.New("connection pool is closed")
+	// ErrAcquireTimeout is returned when no connection was freed before the deadline of the caller
+	ErrAcquireTimeout = errors.New("timed out waiting for a database connection")
+)
+
+// PoolConfig settings of the database connections
+type PoolConfig struct {
+	MaxOpenConns    int           // Connections open at the same time, acquisitions wait beyond it
+	MaxIdleConns    int           // Connections kept open while idle
+	ConnMaxLifetime time.Duration // Age at which a connection is closed once released
+	AcquireTimeout  time.Duration // How long an acquisition waits when the context of the caller has no deadline
 }
 
-// NewConnectionPool creates a new connection pool with the specified maximum size
-func NewConnectionPool(db *gorm.DB, maxSize int) (*ConnectionPool, error) {
-	pool := make(chan *gorm.DB, maxSize)
-
-	for i := 0; i < maxSize; i++ {
-		// Clone the existing *gorm.DB connection
-		clonedDB, err := db.DB()
-		if err != nil {
-			// If any error occurs while creating connections, release all acquired connections and return the error
-			for j := 0; j < i; j++ {
-				releasedDB := <-pool
-				sqlDB, err := releasedDB.DB()
-				if err == nil {
-					sqlDB.Close()
-				}
-			}
-			return nil, err
-		}
+// withDefaults fills the unset settings with their defaults
+func (c PoolConfig) withDefaults() PoolConfig {
+	if c.MaxOpenConns <= 0 {
+		c.MaxOpenConns = DefaultMaxOpenConns
+	}
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if c.MaxIdleConns > c.MaxOpenConns {
+		c.MaxIdleConns = c.MaxOpenConns
+	}
+	if c.ConnMaxLifetime <= 0 {
+		c.ConnMaxLifetime = DefaultConnMaxLifetime
+	}
+	if c.AcquireTimeout <= 0 {
+		c.AcquireTimeout = DefaultAcquireTimeout
+	}
+	return c
+}
 
-		// Create a new *gorm.DB instance using the cloned *sql.DB connection
-		clonedGormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: clonedDB}), &gorm.Config{})
-		if err != nil {
-			// If any error occurs while creating connections, release all acquired connections and return the error
-			for j := 0; j < i; j++ {
-				releasedDB := <-pool
-				sqlDB, err := releasedDB.DB()
-				if err == nil {
-					sqlDB.Close()
-				}
-			}
-			return nil, err
-		}
+// ConnectionPool hands out dedicated connections of the database, shared by the API and the watcher. Acquisitions
+// wait for a connection to be released until the deadline of their context.
+type ConnectionPool struct {
+	db             *gorm.DB
+	sqlDB          *sql.DB
+	acquireTimeout time.Duration
+	closed         int32  // 1 once CloseConnections is called, updated atomically
+	waiting        int64  // Acquisitions in progress, updated atomically
+	timeouts       uint64 // Acquisitions that gave up at their deadline, updated atomically
+}
 
-		pool <- clonedGormDB
+// PoolStats is a snapshot of the usage of the connection pool
+type PoolStats struct {
+	MaxOpen      int           // Connections open at the same time at most
+	Open         int           // Connections open, in use or idle
+	InUse        int           // Connections acquired and not yet released
+	Idle         int           // Connections open and not in use
+	Waiting      int           // Acquisitions waiting for a connection
+	WaitCount    int64         // Acquisitions that had to wait for a connection
+	WaitDuration time.Duration // Total time spent waiting for a connection
+	Timeouts     uint64        // Acquisitions that gave up because no connection was released in time
+}
+
+// NewConnectionPool applies cfg to the connections of db and creates the pool handing them out
+func NewConnectionPool(db *gorm.DB, cfg PoolConfig) (*ConnectionPool, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
 	}
+	cfg = cfg.withDefaults()
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	return &ConnectionPool{
-		maxSize:      maxSize,
-		pool:         pool,
-		connectionMu: sync.RWMutex{},
+		db:             db,
+		sqlDB:          sqlDB,
+		acquireTimeout: cfg.AcquireTimeout,
 	}, nil
 }
 
-// AcquireConnection acquires a database connection from the pool
-func (cp *ConnectionPool) AcquireConnection() (*gorm.DB, error) {
-	select {
-	case db := <-cp.pool:
-		return db, nil
-	default:
-		return nil, errors.New("connection pool is empty")
+// AcquireConnection waits for a connection until ctx is done, or for the acquire timeout when ctx has no deadline.
+// Queries of the returned session run on that connection with ctx, it must be released with ReleaseConnection.
+func (cp *ConnectionPool) AcquireConnection(ctx context.Context) (*gorm.DB, error) {
+	if atomic.LoadInt32(&cp.closed) == 1 {
+		return nil, ErrPoolClosed
+	}
+	waitCtx := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, cp.acquireTimeout)
+		defer cancel()
+	}
+
+	atomic.AddInt64(&cp.waiting, 1)
+	conn, err := cp.sqlDB.Conn(waitCtx)
+	atomic.AddInt64(&cp.waiting, -1)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			atomic.AddUint64(&cp.timeouts, 1)
+			return nil, fmt.Errorf("%w: %v", ErrAcquireTimeout, err)
+		}
+		if atomic.LoadInt32(&cp.closed) == 1 {
+			return nil, ErrPoolClosed
+		}
+		return nil, err
 	}
+
+	// A new statement, so that pinning the connection leaves the shared session untouched
+	session := cp.db.Session(&gorm.Session{NewDB: true, Context: ctx})
+	session.Statement.ConnPool = conn
+	return session, nil
 }
 
-// ReleaseConnection releases a database connection back to the pool
+// ReleaseConnection returns the connection of a session acquired from the pool
 func (cp *ConnectionPool) ReleaseConnection(db *gorm.DB) {
-	cp.pool <- db
+	if conn, ok := db.Statement.ConnPool.(*sql.Conn); ok {
+		conn.Close()
+	}
 }
 
-// CloseConnections closes all the connections in the pool
-func (cp *ConnectionPool) CloseConnections() {
-	cp.connectionMu.Lock()
-	defer cp.connectionMu.Unlock()
-
-	// Check if the channel is already closed
-	if cp.isPoolClosed() {
-		return
-	}
-	close(cp.pool)
-	for db := range cp.pool {
-		sqlDB, err := db.DB()
-		if err == nil {
-			sqlDB.Close()
-		}
+// Stats returns the current usage of the pool
+func (cp *ConnectionPool) Stats() PoolStats {
+	stats := cp.sqlDB.Stats()
+	return PoolStats{
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		Waiting:      int(atomic.LoadInt64(&cp.waiting)),
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration,
+		Timeouts:     atomic.LoadUint64(&cp.timeouts),
 	}
 }
 
-// isPoolClosed checks if the pool channel is closed
-func (cp *ConnectionPool) isPoolClosed() bool {
-	select {
-	case <-cp.pool:
-		return true
-	default:
-		return false
-	}
+// CloseConnections stops handing out connections. The connections still acquired are closed when released, once
+// PostgresDataBase.Close has closed the database.
+func (cp *ConnectionPool) CloseConnections() {
+	atomic.StoreInt32(&cp.closed, 1)
 }
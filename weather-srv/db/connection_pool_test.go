@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// fakeDriver opens connections that run no statement, the pool only hands them out
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func init() {
+	sql.Register("fakepool", fakeDriver{})
+}
+
+// newTestPool creates a pool over fake connections
+func newTestPool(t *testing.T, cfg PoolConfig) *ConnectionPool {
+	t.Helper()
+	sqlDB, err := sql.Open("fakepool", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := NewConnectionPool(db, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestPoolConfigDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  PoolConfig
+		want PoolConfig
+	}{
+		{
+			name: "unset",
+			want: PoolConfig{MaxOpenConns: DefaultMaxOpenConns, MaxIdleConns: DefaultMaxIdleConns, ConnMaxLifetime: DefaultConnMaxLifetime, AcquireTimeout: DefaultAcquireTimeout},
+		},
+		{
+			name: "configured",
+			cfg:  PoolConfig{MaxOpenConns: 5, MaxIdleConns: 2, ConnMaxLifetime: time.Minute, AcquireTimeout: time.Second},
+			want: PoolConfig{MaxOpenConns: 5, MaxIdleConns: 2, ConnMaxLifetime: time.Minute, AcquireTimeout: time.Second},
+		},
+		{
+			name: "idle capped at open",
+			cfg:  PoolConfig{MaxOpenConns: 3, MaxIdleConns: 8},
+			want: PoolConfig{MaxOpenConns: 3, MaxIdleConns: 3, ConnMaxLifetime: DefaultConnMaxLifetime, AcquireTimeout: DefaultAcquireTimeout},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.withDefaults(); got != tt.want {
+				t.Errorf("withDefaults() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcquireConnection(t *testing.T) {
+	pool := newTestPool(t, PoolConfig{MaxOpenConns: 2, AcquireTimeout: 20 * time.Millisecond})
+
+	first, err := pool.AcquireConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pool.AcquireConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := pool.Stats(); stats.MaxOpen != 2 || stats.InUse != 2 {
+		t.Errorf("Stats() = %+v, want 2 of 2 in use", stats)
+	}
+
+	// Without a deadline the acquire timeout applies
+	if _, err := pool.AcquireConnection(context.Background()); !errors.Is(err, ErrAcquireTimeout) {
+		t.Fatalf("AcquireConnection() on a full pool error = %v, want ErrAcquireTimeout", err)
+	}
+	// The deadline of the caller wins over the acquire timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := pool.AcquireConnection(ctx); !errors.Is(err, ErrAcquireTimeout) {
+		t.Fatalf("AcquireConnection() with a deadline error = %v, want ErrAcquireTimeout", err)
+	}
+	if stats := pool.Stats(); stats.Timeouts != 2 || stats.Waiting != 0 {
+		t.Errorf("Stats() = %+v, want 2 timeouts and none waiting", stats)
+	}
+
+	// A released connection is handed to the next waiter
+	acquired := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		db, err := pool.AcquireConnection(ctx)
+		if err == nil {
+			pool.ReleaseConnection(db)
+		}
+		acquired <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.ReleaseConnection(first)
+	if err := <-acquired; err != nil {
+		t.Fatalf("AcquireConnection() after a release error = %v", err)
+	}
+
+	pool.ReleaseConnection(second)
+	if stats := pool.Stats(); stats.InUse != 0 {
+		t.Errorf("Stats() = %+v, want no connection in use", stats)
+	}
+}
+
+func TestAcquireConnectionCanceled(t *testing.T) {
+	pool := newTestPool(t, PoolConfig{MaxOpenConns: 1})
+	held, err := pool.AcquireConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.ReleaseConnection(held)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.AcquireConnection(ctx)
+	if err == nil || errors.Is(err, ErrAcquireTimeout) {
+		t.Fatalf("AcquireConnection() with a canceled context error = %v, want the cancellation", err)
+	}
+	if stats := pool.Stats(); stats.Timeouts != 0 {
+		t.Errorf("Stats() = %+v, a cancellation is not a timeout", stats)
+	}
+}
+
+func TestCloseConnections(t *testing.T) {
+	pool := newTestPool(t, PoolConfig{})
+	held, err := pool.AcquireConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.CloseConnections()
+	if _, err := pool.AcquireConnection(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("AcquireConnection() after close error = %v, want ErrPoolClosed", err)
+	}
+	// Connections acquired before the close are still released
+	pool.ReleaseConnection(held)
+	if stats := pool.Stats(); stats.InUse != 0 {
+		t.Errorf("Stats() = %+v, want no connection in use", stats)
+	}
+}
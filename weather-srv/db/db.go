@@ -1,7 +1,8 @@
 package db
 
 import (
-	"log"
+	"fmt"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
@@ -23,25 +24,67 @@ type PostgresStorageConfig struct {
 
 type PostgresDataBase struct {
 	DB     *gorm.DB
+	Pool   *ConnectionPool // Connections shared by the API and the watcher
 	Logger *logrus.Logger
 }
 
-func InitialMigration(dbURL string, logger *logrus.Logger) (*PostgresDataBase, error) {
-	gormConfig := &gorm.Config{Logger: gorm_logger.Default.LogMode(gorm_logger.Silent), DisableForeignKeyConstraintWhenMigrating: true}
+func InitialMigration(dbURL string, logger *logrus.Logger, poolCfg PoolConfig) (*PostgresDataBase, error) {
+	gormConfig := &gorm.Config{Logger: newGormLogger(logger), DisableForeignKeyConstraintWhenMigrating: true}
 
 	db, err := gorm.Open(postgres.Open(dbURL), gormConfig)
 	if err != nil {
-		log.Panicf("failed to connect database %s", err)
+		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
 
-	sqlDB, err := db.DB()
+	// The connection settings also apply to the migrations
+	pool, err := NewConnectionPool(db, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database handle: %w", err)
+	}
+
+	// Reports stored before stream sequences existed are numbered once the column is added
+	backfillSequences := db.Migrator().HasTable(&WeatherReport{}) && !db.Migrator().HasColumn(&WeatherReport{}, "StreamSequence")
 
 	// run migrations
-	if err := db.AutoMigrate(&Membership{}, &WeatherReport{}, &EventLog{}); err != nil {
-		log.Panicf("failed to automigrate tables %s", err)
+	if err := db.AutoMigrate(&Membership{}, &WeatherReport{}, &EventLog{}, &WebhookSubscription{}, &WebhookDelivery{}, &ObservationRollup{}, &PendingAggregation{}, &ReportScore{}, &MerkleEpoch{}, &MerkleLeaf{}, &IdempotencyRecord{}, &AuthNonce{}, &AuthSession{}, &AdminAuditLog{}); err != nil {
+		return nil, fmt.Errorf("failed to automigrate tables: %w", err)
 	}
+	if backfillSequences {
+		if err := BackfillStreamSequences(db); err != nil {
+			return nil, fmt.Errorf("failed to number stored reports: %w", err)
+		}
+	}
+	logger.Info("Database migrated")
 
-	sqlDB.SetMaxOpenConns(10) // Set the maximum number of open connections
+	return &PostgresDataBase{DB: db, Pool: pool, Logger: logger}, nil
+}
 
-	return &PostgresDataBase{DB: db, Logger: logger}, err
+// Close closes the connection pool and the connections of the database, it is called once nothing uses the
+// database anymore
+func (p *PostgresDataBase) Close() error {
+	p.Pool.CloseConnections()
+	sqlDB, err := p.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// slowQueryThreshold is the duration above which a query is logged as slow
+const slowQueryThreshold = 200 * time.Millisecond
+
+// newGormLogger logs the slow and failed queries through logger, every query is logged at trace level
+func newGormLogger(logger *logrus.Logger) gorm_logger.Interface {
+	level := gorm_logger.Warn
+	switch {
+	case logger.IsLevelEnabled(logrus.TraceLevel):
+		level = gorm_logger.Info
+	case !logger.IsLevelEnabled(logrus.WarnLevel):
+		level = gorm_logger.Error
+	}
+	return gorm_logger.New(logger, gorm_logger.Config{
+		SlowThreshold:             slowQueryThreshold,
+		LogLevel:                  level,
+		IgnoreRecordNotFoundError: true,
+	})
 }
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClaimIdempotencyKey stores record as in progress unless a live record exists for its address and key.
+// It returns the existing record and false when the key is already claimed. Expired records of the address, including
+// claims whose request never stored a response, are deleted first, which keeps the table bounded by the keys members
+// used within the TTL.
+func ClaimIdempotencyKey(DB *gorm.DB, record *IdempotencyRecord, now int64) (*IdempotencyRecord, bool, error) {
+	// Deleted for good, a soft deleted row would still hold the unique key
+	if err := DB.Unscoped().Where("address = ? AND expires_at <= ?", record.Address, now).Delete(&IdempotencyRecord{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return record, true, nil
+	}
+
+	var existing IdempotencyRecord
+	if err := DB.Where("address = ? AND key = ?", record.Address, record.Key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request that claimed the record and extends it to the TTL.
+func CompleteIdempotencyKey(DB *gorm.DB, record *IdempotencyRecord) error {
+	return DB.Model(record).Updates(map[string]interface{}{
+		"status_code": record.StatusCode,
+		"headers":     record.Headers,
+		"body":        record.Body,
+		"expires_at":  record.ExpiresAt,
+	}).Error
+}
+
+// ReleaseIdempotencyKey deletes the record so that the key can be retried.
+func ReleaseIdempotencyKey(DB *gorm.DB, record *IdempotencyRecord) error {
+	return DB.Unscoped().Delete(record).Error
+}
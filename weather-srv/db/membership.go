@@ -1,6 +1,25 @@
 package db
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MembershipOrigin identifies the chain and registration contract a membership status came from
+type MembershipOrigin struct {
+	ChainName            string
+	RegistrationContract string
+}
+
+// MembershipTransition is a status change of a membership recorded from a contract event
+type MembershipTransition struct {
+	EventType       string
+	ChainName       string
+	BlockHeight     uint64
+	TransactionHash string
+	CreatedAt       int64
+}
 
 // UpdateMemberShipStatus updates the membership status for the given address in the database.
 func UpdateMemberShipStatus(DB *gorm.DB, address string, status MembershipStatus) error {
@@ -22,3 +41,108 @@ func FindMemberShip(DB *gorm.DB, address string) (*Membership, error) {
 func CreateMembership(DB *gorm.DB, membership *Membership) error {
 	return DB.Create(membership).Error
 }
+
+// UpdateMembershipOrigin updates the chain and registration contract of the membership for the given address.
+func UpdateMembershipOrigin(DB *gorm.DB, address string, origin MembershipOrigin) error {
+	return DB.Model(&Membership{}).Where("address = ?", address).
+		Updates(map[string]interface{}{"chain_name": origin.ChainName, "registration_contract": origin.RegistrationContract}).Error
+}
+
+// IsSuspended reports whether the membership has an off-chain suspension active at now.
+func (m Membership) IsSuspended(now int64) bool {
+	return m.Suspended && (m.SuspendedUntil == 0 || now < m.SuspendedUntil)
+}
+
+// FindMemberships returns a page of memberships ordered by ID along with the total count, an empty status matches every membership.
+// The Suspended status matches the memberships with a suspension active at now, whatever their chain status.
+func FindMemberships(DB *gorm.DB, status string, now int64, limit, offset int) ([]Membership, int64, error) {
+	var memberships []Membership
+	var total int64
+	query := DB.Model(&Membership{})
+	switch MembershipStatus(status) {
+	case "":
+	case Suspended:
+		query = query.Where("suspended = ? AND (suspended_until = 0 OR suspended_until > ?)", true, now)
+	default:
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id ASC").Limit(limit).Offset(offset).Find(&memberships).Error
+	return memberships, total, err
+}
+
+// CountMemberReports counts the reports of a membership created at or after since, a zero since counts every report.
+func CountMemberReports(DB *gorm.DB, membershipID uint, since time.Time) (int64, error) {
+	var count int64
+	query := DB.Model(&WeatherReport{}).Where("membership_id = ?", membershipID)
+	if !since.IsZero() {
+		query = query.Where("created_at >= ?", since)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// FindMembershipTransitions returns the latest status changes of the membership for the given address, newest first.
+func FindMembershipTransitions(DB *gorm.DB, address string, limit int) ([]MembershipTransition, error) {
+	var transitions []MembershipTransition
+	err := DB.Model(&EventLog{}).
+		Select("event_type, chain_name, block_height, transaction_hash, created_at").
+		Where("address = ? AND event_type <> ''", address).
+		Order("block_height DESC, id DESC").Limit(limit).Scan(&transitions).Error
+	return transitions, err
+}
+
+// MembershipOverrides are the settings of a membership an admin can override, nil fields are left unchanged
+type MembershipOverrides struct {
+	Region     *string
+	RatePeriod *int64
+	RateWindow *int64
+}
+
+// UpdateMembershipSuspension suspends the membership for the given address until the given unix time, 0 for no expiry,
+// or lifts its suspension when suspended is false. It returns gorm.ErrRecordNotFound when there is no such membership.
+func UpdateMembershipSuspension(DB *gorm.DB, address string, suspended bool, reason string, until int64) error {
+	result := DB.Model(&Membership{}).Where("address = ?", address).
+		Updates(map[string]interface{}{"suspended": suspended, "suspension_reason": reason, "suspended_until": until})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateMembershipOverrides applies the overrides to the membership for the given address.
+// It returns gorm.ErrRecordNotFound when there is no such membership.
+func UpdateMembershipOverrides(DB *gorm.DB, address string, overrides MembershipOverrides) error {
+	updates := map[string]interface{}{}
+	if overrides.Region != nil {
+		updates["region"] = *overrides.Region
+	}
+	if overrides.RatePeriod != nil {
+		updates["rate_period"] = *overrides.RatePeriod
+	}
+	if overrides.RateWindow != nil {
+		updates["rate_window"] = *overrides.RateWindow
+	}
+	if len(updates) == 0 {
+		_, err := FindMemberShip(DB, address)
+		return err
+	}
+	result := DB.Model(&Membership{}).Where("address = ?", address).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateMemberLastCall stores the time of the last report of a member, leaving the other columns to their owners.
+func UpdateMemberLastCall(DB *gorm.DB, membershipID uint, lastCall int64) error {
+	return DB.Model(&Membership{}).Where("id = ?", membershipID).UpdateColumn("last_call", lastCall).Error
+}
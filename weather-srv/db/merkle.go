@@ -0,0 +1,88 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+// ReportLeaf holds the fields of a report committed in a Merkle tree
+type ReportLeaf struct {
+	ID              uint
+	TypedDataHash   string
+	ServerTimestamp int64
+}
+
+// FindReportLeaves returns the reports with a server timestamp within [start, end), ordered by ID.
+func FindReportLeaves(DB *gorm.DB, start, end int64) ([]ReportLeaf, error) {
+	var leaves []ReportLeaf
+	err := DB.Model(&WeatherReport{}).Select("id, typed_data_hash, server_timestamp").
+		Where("server_timestamp >= ? AND server_timestamp < ? AND typed_data_hash <> ''", start, end).
+		Order("id ASC").Scan(&leaves).Error
+	return leaves, err
+}
+
+// FindNextReportTimestamp returns the lowest server timestamp at or after from, and false when there is none.
+func FindNextReportTimestamp(DB *gorm.DB, from int64) (int64, bool, error) {
+	var timestamps []int64
+	err := DB.Model(&WeatherReport{}).Where("server_timestamp >= ? AND typed_data_hash <> ''", from).
+		Order("server_timestamp ASC").Limit(1).Pluck("server_timestamp", &timestamps).Error
+	if err != nil || len(timestamps) == 0 {
+		return 0, false, err
+	}
+	return timestamps[0], true, nil
+}
+
+// FindLastMerkleEpoch returns the last committed epoch.
+func FindLastMerkleEpoch(DB *gorm.DB) (*MerkleEpoch, error) {
+	epoch := &MerkleEpoch{}
+	if err := DB.Order("epoch DESC").First(epoch).Error; err != nil {
+		return nil, err
+	}
+	return epoch, nil
+}
+
+// FindMerkleEpoch returns the committed epoch with the given index.
+func FindMerkleEpoch(DB *gorm.DB, epoch int64) (*MerkleEpoch, error) {
+	merkleEpoch := &MerkleEpoch{}
+	if err := DB.Where("epoch = ?", epoch).First(merkleEpoch).Error; err != nil {
+		return nil, err
+	}
+	return merkleEpoch, nil
+}
+
+// CreateMerkleEpoch stores a committed epoch with its leaves, in the order of the tree.
+func CreateMerkleEpoch(DB *gorm.DB, epoch *MerkleEpoch, leaves []MerkleLeaf) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(epoch).Error; err != nil {
+			return err
+		}
+		if len(leaves) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(leaves, 500).Error
+	})
+}
+
+// FindMerkleLeaf returns the leaf of a committed report.
+func FindMerkleLeaf(DB *gorm.DB, reportID uint) (*MerkleLeaf, error) {
+	leaf := &MerkleLeaf{}
+	if err := DB.Where("weather_report_id = ?", reportID).First(leaf).Error; err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+// FindMerkleLeaves returns the leaves committed in an epoch, in the order of the tree.
+func FindMerkleLeaves(DB *gorm.DB, epoch int64) ([]MerkleLeaf, error) {
+	var leaves []MerkleLeaf
+	err := DB.Where("epoch = ?", epoch).Order("leaf_index ASC").Find(&leaves).Error
+	return leaves, err
+}
+
+// FindWeatherReport returns the weather report with the given ID.
+func FindWeatherReport(DB *gorm.DB, id uint) (*WeatherReport, error) {
+	report := &WeatherReport{}
+	if err := DB.First(report, id).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -8,10 +8,19 @@ import (
 
 // Membership represents the membership model
 type Membership struct {
-	gorm.Model        // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
-	Address    string `gorm:"unique_index"` // Address of the membership (unique index)
-	Status     string // Status of the membership
-	LastCall   int64  // Last call timestamp for the membership
+	gorm.Model                   // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	Address              string  `gorm:"unique_index"` // Address of the membership (unique index)
+	Status               string  // Status of the membership
+	LastCall             int64   // Last call timestamp for the membership
+	RatePeriod           int64   // Seconds between reporting windows, 0 uses the service default
+	RateWindow           int64   // Seconds a reporting window stays open, 0 uses the service default
+	Region               string  // Region the member reports for
+	Reputation           float64 `gorm:"default:1"` // Share of the member's recent scored values that agreed with consensus
+	ChainName            string  // Chain of the registration contract that last changed the status
+	RegistrationContract string  // Registration contract that last changed the status
+	Suspended            bool    // Whether an admin suspended the member off-chain, kept apart from the chain driven Status
+	SuspensionReason     string  // Reason of the suspension given by the admin
+	SuspendedUntil       int64   // Unix time the suspension expires, 0 until it is lifted
 }
 
 // MembershipStatus represents the possible status values for the membership
@@ -21,13 +30,19 @@ const (
 	Unregistered MembershipStatus = "Unregistered"
 	Registered   MembershipStatus = "Registered"
 	Resigned     MembershipStatus = "Resigned"
+	// Suspended is reported for members with an active off-chain suspension, it is never stored in Status
+	Suspended MembershipStatus = "Suspended"
 )
 
 // WeatherReport represents the weather report model
 type WeatherReport struct {
-	gorm.Model          // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
-	MembershipID uint   // ID of the associated membership
-	Report       string // Weather report data
+	gorm.Model             // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	MembershipID    uint   // ID of the associated membership
+	Report          string // Weather report data
+	Region          string // Region of the member at the time of the report
+	TypedDataHash   string // Hex EIP-712 hash of the signed report
+	ServerTimestamp int64  `gorm:"index"`           // Unix time the service accepted the report
+	StreamSequence  uint64 `gorm:"index;default:0"` // Position of the report in commit order, 0 until the stream sequencer numbers it
 }
 
 // EventLog represents the event log model
@@ -40,5 +55,125 @@ type EventLog struct {
 	ChainName       string    // Name of the blockchain
 	TransactionHash string    // Hash of the transaction
 	Address         string    // Address associated with the event
+	EventType       string    // Type of the event, ParticipantRegistered or ParticipantResigned
 	Timestamp       time.Time // Timestamp of the log
 }
+
+// WebhookSubscription represents a webhook endpoint subscribed to service events
+type WebhookSubscription struct {
+	gorm.Model        // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	URL        string // Endpoint receiving the deliveries
+	Secret     string `json:"-"` // Secret used to sign the deliveries
+	EventTypes string // Comma separated event types, empty subscribes to every event
+	Active     bool   // Inactive subscriptions receive no deliveries
+}
+
+// WebhookDelivery represents a webhook event sent, or to be sent, to a subscription
+type WebhookDelivery struct {
+	gorm.Model                // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	SubscriptionID uint       `gorm:"index"` // ID of the associated subscription
+	EventType      string     // Type of the event
+	Payload        string     // JSON body of the delivery
+	Status         string     `gorm:"index"` // Pending, Delivered or Failed
+	Attempts       int        // Number of attempts made
+	ResponseCode   int        // HTTP status of the last attempt
+	LastError      string     // Error of the last attempt
+	NextAttemptAt  time.Time  // Time of the next attempt while pending
+	DeliveredAt    *time.Time // Time of the successful attempt
+}
+
+// ObservationRollup represents the aggregate of one metric of the reports of a region within a fixed window
+type ObservationRollup struct {
+	gorm.Model              // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	WindowStart   time.Time `gorm:"uniqueIndex:idx_observation_rollup"` // Start of the window
+	WindowSeconds int64     `gorm:"uniqueIndex:idx_observation_rollup"` // Length of the window
+	Region        string    `gorm:"uniqueIndex:idx_observation_rollup"` // Region of the reports
+	Metric        string    `gorm:"uniqueIndex:idx_observation_rollup"` // Metric aggregated, "value" for plain numeric reports
+	Count         int64     // Number of values
+	ReporterCount int64     // Number of distinct members that reported
+	Mean          float64
+	WeightedMean  float64 // Mean weighted by the reputation of the reporters
+	Median        float64
+	Min           float64
+	Max           float64
+	OutlierCount  int64 // Number of values flagged as outliers
+}
+
+// PendingAggregation marks a report whose windows are not aggregated yet. It is created in the transaction storing
+// the report, so it only becomes visible to the aggregator once the report is committed.
+type PendingAggregation struct {
+	gorm.Model           // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	WeatherReportID uint `gorm:"uniqueIndex"` // ID of the report to aggregate
+}
+
+// ReportScore represents how far a metric of a report deviates from the consensus of the other reporters in its window and region
+type ReportScore struct {
+	gorm.Model              // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	WeatherReportID uint    `gorm:"uniqueIndex:idx_report_score"` // ID of the scored report
+	Metric          string  `gorm:"uniqueIndex:idx_report_score"` // Metric scored
+	MembershipID    uint    `gorm:"index"`                        // ID of the member that reported
+	Value           float64 // Reported value
+	Consensus       float64 // Median of the other reporters' values
+	Deviation       float64 // Robust z-score of the value against the consensus
+	Outlier         bool    // Whether the deviation exceeds the outlier threshold
+}
+
+// MerkleEpoch represents the Merkle root committed over the reports accepted within an epoch
+type MerkleEpoch struct {
+	gorm.Model          // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	Epoch        int64  `gorm:"uniqueIndex"` // Index of the epoch, server timestamp divided by the epoch length
+	EpochSeconds int64  // Length of the epoch
+	StartTime    int64  // First server timestamp of the epoch
+	EndTime      int64  // Server timestamp after the epoch, exclusive
+	Root         string // Hex Merkle root over the report leaves ordered by report ID
+	LeafCount    int    // Number of reports in the epoch
+}
+
+// MerkleLeaf represents a report committed in the Merkle tree of an epoch, proofs are built from the stored leaves
+// so that reports stored or deleted after the commit do not change them
+type MerkleLeaf struct {
+	gorm.Model             // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	Epoch           int64  `gorm:"uniqueIndex:idx_merkle_leaf"` // Index of the epoch the report is committed in
+	LeafIndex       int    `gorm:"uniqueIndex:idx_merkle_leaf"` // Position of the leaf in the tree
+	WeatherReportID uint   `gorm:"uniqueIndex"`                 // ID of the committed report
+	Leaf            string // Hex leaf of the report
+}
+
+// IdempotencyRecord represents the stored response of a report submission sent with an Idempotency-Key header
+type IdempotencyRecord struct {
+	gorm.Model         // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	Address     string `gorm:"uniqueIndex:idx_idempotency_key"` // Address of the report, keys are scoped per member
+	Key         string `gorm:"uniqueIndex:idx_idempotency_key"` // Idempotency-Key header sent by the client
+	RequestHash string // Hex SHA-256 of the request body
+	StatusCode  int    // Status of the stored response, 0 while the first request is in progress
+	Headers     string // JSON object of the replayed response headers
+	Body        string // Body of the stored response
+	ExpiresAt   int64  `gorm:"index"` // Unix time after which the key may be reused, a short lease while in progress
+}
+
+// AuthNonce represents a single use nonce handed out for a Sign-In with Ethereum message
+type AuthNonce struct {
+	gorm.Model        // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	Nonce      string `gorm:"uniqueIndex"` // Random nonce the message must contain
+	ExpiresAt  int64  `gorm:"index"`       // Unix time after which the nonce is rejected
+	Used       bool   // Whether a sign-in already consumed the nonce
+}
+
+// AuthSession represents a sign-in session of a member, refreshed with a rotating refresh token
+type AuthSession struct {
+	gorm.Model              // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	Address          string `gorm:"index"`       // Checksummed address that signed in
+	RefreshTokenHash string `gorm:"uniqueIndex"` // Hex SHA-256 of the current refresh token
+	ExpiresAt        int64  // Unix time the current refresh token expires
+	Revoked          bool   // Whether the member signed out
+}
+
+// AdminAuditLog represents an action taken through the admin API
+type AdminAuditLog struct {
+	gorm.Model        // GORM model for common fields (ID, CreatedAt, UpdatedAt, DeletedAt)
+	Action     string `gorm:"index"` // Action taken, e.g. member.suspended
+	Address    string `gorm:"index"` // Address of the member the action applies to
+	Details    string // JSON object describing the change
+	RequestID  string // ID of the admin request
+	RemoteAddr string // Client IP of the admin request
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ObservationQuery filters the observation rollups, zero fields match everything
+type ObservationQuery struct {
+	Region        string
+	Metric        string
+	WindowSeconds int64
+	From          time.Time
+	To            time.Time
+	Limit         int
+	Offset        int
+}
+
+// FindReportsBetween returns the reports of region created within [from, to).
+func FindReportsBetween(DB *gorm.DB, from, to time.Time, region string) ([]ReportRecord, error) {
+	var records []ReportRecord
+	err := DB.Table("weather_reports").
+		Select(reportRecordColumns).
+		Joins("JOIN memberships ON memberships.id = weather_reports.membership_id").
+		Where("weather_reports.created_at >= ? AND weather_reports.created_at < ? AND weather_reports.region = ? AND weather_reports.deleted_at IS NULL", from, to, region).
+		Order("weather_reports.id ASC").Scan(&records).Error
+	return records, err
+}
+
+// UpsertObservationRollup creates the rollup or replaces the aggregates of the existing one for the same window, region and metric.
+func UpsertObservationRollup(DB *gorm.DB, rollup *ObservationRollup) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "window_start"}, {Name: "window_seconds"}, {Name: "region"}, {Name: "metric"}},
+		DoUpdates: clause.AssignmentColumns([]string{"count", "reporter_count", "mean", "weighted_mean", "median", "min", "max", "outlier_count", "updated_at"}),
+	}).Create(rollup).Error
+}
+
+// FindObservationRollups returns the rollups matching the query, newest window first.
+func FindObservationRollups(DB *gorm.DB, q ObservationQuery) ([]ObservationRollup, error) {
+	var rollups []ObservationRollup
+	query := DB.Model(&ObservationRollup{})
+	if q.Region != "" {
+		query = query.Where("region = ?", q.Region)
+	}
+	if q.Metric != "" {
+		query = query.Where("metric = ?", q.Metric)
+	}
+	if q.WindowSeconds > 0 {
+		query = query.Where("window_seconds = ?", q.WindowSeconds)
+	}
+	if !q.From.IsZero() {
+		query = query.Where("window_start >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("window_start < ?", q.To)
+	}
+	err := query.Order("window_start DESC, region ASC, metric ASC").Limit(q.Limit).Offset(q.Offset).Find(&rollups).Error
+	return rollups, err
+}
+
+// CreatePendingAggregation marks the report as not aggregated yet, it must run in the transaction storing the report.
+func CreatePendingAggregation(DB *gorm.DB, reportID uint) error {
+	return DB.Create(&PendingAggregation{WeatherReportID: reportID}).Error
+}
+
+// FindPendingReports returns up to limit reports marked as not aggregated yet, in the order they were committed.
+func FindPendingReports(DB *gorm.DB, limit int) ([]ReportRecord, error) {
+	var records []ReportRecord
+	err := DB.Table("pending_aggregations").
+		Select(reportRecordColumns).
+		Joins("JOIN weather_reports ON weather_reports.id = pending_aggregations.weather_report_id").
+		Joins("JOIN memberships ON memberships.id = weather_reports.membership_id").
+		Order("pending_aggregations.id ASC").Limit(limit).Scan(&records).Error
+	return records, err
+}
+
+// DeletePendingAggregations removes the marks of the aggregated reports.
+func DeletePendingAggregations(DB *gorm.DB, reportIDs []uint) error {
+	return DB.Unscoped().Where("weather_report_id IN ?", reportIDs).Delete(&PendingAggregation{}).Error
+}
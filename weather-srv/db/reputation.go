@@ -0,0 +1,61 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReputationSummary is the outcome of the recent scored values of a member
+type ReputationSummary struct {
+	Scored   int64
+	Outliers int64
+}
+
+// UpsertReportScore creates the score or replaces the existing one of the same report and metric.
+func UpsertReportScore(DB *gorm.DB, score *ReportScore) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "weather_report_id"}, {Name: "metric"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "consensus", "deviation", "outlier", "updated_at"}),
+	}).Create(score).Error
+}
+
+// SummarizeRecentScores counts the outliers among the last window scores of a member.
+func SummarizeRecentScores(DB *gorm.DB, membershipID uint, window int) (ReputationSummary, error) {
+	var summary ReputationSummary
+	recent := DB.Model(&ReportScore{}).Select("outlier").
+		Where("membership_id = ?", membershipID).Order("weather_report_id DESC").Limit(window)
+	err := DB.Table("(?) AS recent", recent).
+		Select("COUNT(*) AS scored, COUNT(*) FILTER (WHERE outlier) AS outliers").
+		Scan(&summary).Error
+	return summary, err
+}
+
+// UpdateMemberReputation stores the reputation of a member.
+func UpdateMemberReputation(DB *gorm.DB, membershipID uint, reputation float64) error {
+	return DB.Model(&Membership{}).Where("id = ?", membershipID).UpdateColumn("reputation", reputation).Error
+}
+
+// FindReputations returns the reputation of the given members by ID.
+func FindReputations(DB *gorm.DB, membershipIDs []uint) (map[uint]float64, error) {
+	var members []Membership
+	if err := DB.Select("id, reputation").Where("id IN ?", membershipIDs).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	reputations := make(map[uint]float64, len(members))
+	for _, m := range members {
+		reputations[m.ID] = m.Reputation
+	}
+	return reputations, nil
+}
+
+// FindOutlierScores returns the metric and report of every outlier score of the reports of region created within [from, to).
+func FindOutlierScores(DB *gorm.DB, from, to time.Time, region string) ([]ReportScore, error) {
+	var scores []ReportScore
+	err := DB.Model(&ReportScore{}).Select("report_scores.weather_report_id, report_scores.metric").
+		Joins("JOIN weather_reports ON weather_reports.id = report_scores.weather_report_id").
+		Where("report_scores.outlier AND weather_reports.created_at >= ? AND weather_reports.created_at < ? AND weather_reports.region = ? AND weather_reports.deleted_at IS NULL", from, to, region).
+		Find(&scores).Error
+	return scores, err
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reportRecordColumns are the columns selected into a ReportRecord
+const reportRecordColumns = "weather_reports.id, weather_reports.membership_id, memberships.address, weather_reports.region, weather_reports.report, weather_reports.created_at, weather_reports.stream_sequence"
+
+// ReportRecord is a weather report joined with the address of its member
+type ReportRecord struct {
+	ID             uint
+	MembershipID   uint
+	Address        string
+	Region         string
+	Report         string
+	CreatedAt      time.Time
+	StreamSequence uint64
+}
+
+// FindReportsAfter returns up to limit reports with an ID greater than afterID, oldest first.
+// Empty address or region match every report.
+func FindReportsAfter(DB *gorm.DB, afterID uint, address, region string, limit int) ([]ReportRecord, error) {
+	var records []ReportRecord
+	query := DB.Table("weather_reports").
+		Select(reportRecordColumns).
+		Joins("JOIN memberships ON memberships.id = weather_reports.membership_id").
+		Where("weather_reports.id > ? AND weather_reports.deleted_at IS NULL", afterID)
+	if address != "" {
+		query = query.Where("LOWER(memberships.address) = LOWER(?)", address)
+	}
+	if region != "" {
+		query = query.Where("LOWER(weather_reports.region) = LOWER(?)", region)
+	}
+	err := query.Order("weather_reports.id ASC").Limit(limit).Scan(&records).Error
+	return records, err
+}
+
+// streamSequenceLock is the key of the advisory lock serializing the numbering of reports across instances
+const streamSequenceLock = 7_215_421_901
+
+// AssignStreamSequences numbers up to limit committed reports that have no stream sequence yet, following the
+// highest sequence, and returns how many it numbered. Numbering runs under a transaction scoped advisory lock, so
+// the sequences of a run become visible together and after those of every earlier run.
+func AssignStreamSequences(DB *gorm.DB, limit int) (int64, error) {
+	var numbered int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", streamSequenceLock).Error; err != nil {
+			return err
+		}
+		result := tx.Exec(`UPDATE weather_reports SET stream_sequence = numbered.seq FROM (
+			SELECT id, (SELECT COALESCE(MAX(stream_sequence), 0) FROM weather_reports) + ROW_NUMBER() OVER (ORDER BY id) AS seq
+			FROM weather_reports WHERE stream_sequence = 0 ORDER BY id LIMIT ?
+		) AS numbered WHERE weather_reports.id = numbered.id`, limit)
+		numbered = result.RowsAffected
+		return result.Error
+	})
+	return numbered, err
+}
+
+// BackfillStreamSequences numbers the reports stored before the stream_sequence column existed after their ID.
+// It only runs while no report is numbered yet, later reports are numbered by AssignStreamSequences.
+func BackfillStreamSequences(DB *gorm.DB) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", streamSequenceLock).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE weather_reports SET stream_sequence = id WHERE stream_sequence = 0
+			AND NOT EXISTS (SELECT 1 FROM weather_reports WHERE stream_sequence > 0)`).Error
+	})
+}
+
+// MaxStreamSequence returns the highest stream sequence assigned, 0 when no report is numbered yet
+func MaxStreamSequence(DB *gorm.DB) (uint64, error) {
+	var sequence uint64
+	err := DB.Table("weather_reports").Select("COALESCE(MAX(stream_sequence), 0)").Scan(&sequence).Error
+	return sequence, err
+}
+
+// FindReportsAfterSequence returns up to limit reports with a stream sequence greater than afterSequence, in
+// sequence order. Empty address or region match every report.
+func FindReportsAfterSequence(DB *gorm.DB, afterSequence uint64, address, region string, limit int) ([]ReportRecord, error) {
+	var records []ReportRecord
+	query := DB.Table("weather_reports").
+		Select(reportRecordColumns).
+		Joins("JOIN memberships ON memberships.id = weather_reports.membership_id").
+		Where("weather_reports.stream_sequence > ? AND weather_reports.deleted_at IS NULL", afterSequence)
+	if address != "" {
+		query = query.Where("LOWER(memberships.address) = LOWER(?)", address)
+	}
+	if region != "" {
+		query = query.Where("LOWER(weather_reports.region) = LOWER(?)", region)
+	}
+	err := query.Order("weather_reports.stream_sequence ASC").Limit(limit).Scan(&records).Error
+	return records, err
+}
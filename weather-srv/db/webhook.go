@@ -0,0 +1,87 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookDeliveryStatus represents the possible status values of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "Pending"
+	DeliveryDelivered WebhookDeliveryStatus = "Delivered"
+	DeliveryFailed    WebhookDeliveryStatus = "Failed"
+)
+
+// CreateWebhookSubscription creates a new webhook subscription in the database.
+func CreateWebhookSubscription(DB *gorm.DB, subscription *WebhookSubscription) error {
+	return DB.Create(subscription).Error
+}
+
+// FindWebhookSubscriptions returns every webhook subscription.
+func FindWebhookSubscriptions(DB *gorm.DB) ([]WebhookSubscription, error) {
+	var subscriptions []WebhookSubscription
+	err := DB.Order("id ASC").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// FindActiveWebhookSubscriptions returns the active webhook subscriptions.
+func FindActiveWebhookSubscriptions(DB *gorm.DB) ([]WebhookSubscription, error) {
+	var subscriptions []WebhookSubscription
+	err := DB.Where("active = ?", true).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// DeleteWebhookSubscription deletes the webhook subscription with the given ID.
+func DeleteWebhookSubscription(DB *gorm.DB, id uint) error {
+	result := DB.Delete(&WebhookSubscription{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateWebhookDeliveries creates pending deliveries in the database.
+func CreateWebhookDeliveries(DB *gorm.DB, deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return DB.Create(&deliveries).Error
+}
+
+// ClaimDueWebhookDeliveries claims up to limit pending deliveries whose next attempt is due by pushing their next
+// attempt lease into the future, rows claimed by another instance are skipped. The claimed deliveries are returned
+// with their next attempt set to now, so saving one that was not attempted releases it.
+func ClaimDueWebhookDeliveries(DB *gorm.DB, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	due := DB.Model(&WebhookDelivery{}).Select("id").
+		Where("status = ? AND next_attempt_at <= ? AND deleted_at IS NULL", DeliveryPending, now).
+		Order("next_attempt_at ASC").Limit(limit).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+	err := DB.Model(&deliveries).Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Update("next_attempt_at", now.Add(lease)).Error
+	for i := range deliveries {
+		deliveries[i].NextAttemptAt = now
+	}
+	return deliveries, err
+}
+
+// FindWebhookDeliveries returns the latest deliveries of a subscription, newest first.
+func FindWebhookDeliveries(DB *gorm.DB, subscriptionID uint, limit, offset int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := DB.Where("subscription_id = ?", subscriptionID).
+		Order("id DESC").Limit(limit).Offset(offset).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// SaveWebhookDelivery saves the outcome of a delivery attempt.
+func SaveWebhookDelivery(DB *gorm.DB, delivery *WebhookDelivery) error {
+	return DB.Save(delivery).Error
+}
@@ -0,0 +1,136 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultTimeout    = 2 * time.Second
+	DefaultMaxHeadLag = 500
+)
+
+// Config readiness check configuration
+type Config struct {
+	Timeout    time.Duration // Time each check may take before its component is reported down
+	MaxHeadLag uint64        // Blocks the watcher may be behind the chain head before it is reported degraded
+}
+
+// Status is the state of a component or of the whole service
+type Status string
+
+const (
+	Up       Status = "up"       // Working as expected
+	Degraded Status = "degraded" // Working outside of its thresholds
+	Down     Status = "down"     // Not working
+)
+
+// Result is the outcome of a check, Details are returned to the prober as is
+type Result struct {
+	Status  Status                 `json:"status"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// Check probes one component, critical components that are not up fail readiness
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) Result
+}
+
+// ComponentReport is the result of a check in a Report
+type ComponentReport struct {
+	Result
+	Critical   bool  `json:"critical"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Report is the outcome of every check, Ready is false when a critical component is not up
+type Report struct {
+	Status     Status                     `json:"status"`
+	Ready      bool                       `json:"ready"`
+	CheckedAt  int64                      `json:"checked_at"`
+	Components map[string]ComponentReport `json:"components"`
+}
+
+// Checker runs the registered checks concurrently, each within the timeout
+type Checker struct {
+	timeout time.Duration
+	mu      sync.RWMutex
+	checks  []Check
+}
+
+// NewChecker creates a new Checker instance
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a check
+func (c *Checker) Register(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check)
+}
+
+// Run runs every check and aggregates their results. The service is down when a critical component is down,
+// degraded when any other component is not up.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]Check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	reports := make([]ComponentReport, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			reports[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: Up, Ready: true, CheckedAt: time.Now().Unix(), Components: make(map[string]ComponentReport, len(checks))}
+	for i, check := range checks {
+		r := reports[i]
+		report.Components[check.Name] = r
+		if r.Status == Up {
+			continue
+		}
+		if check.Critical {
+			report.Ready = false
+		}
+		if check.Critical && r.Status == Down {
+			report.Status = Down
+		} else if report.Status == Up {
+			report.Status = Degraded
+		}
+	}
+	return report
+}
+
+// run runs a check within the timeout, a check that does not return in time is down
+func (c *Checker) run(ctx context.Context, check Check) ComponentReport {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan Result, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	var result Result
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = Result{Status: Down, Error: "check timed out"}
+	}
+	return ComponentReport{Result: result, Critical: check.Critical, DurationMS: time.Since(start).Milliseconds()}
+}
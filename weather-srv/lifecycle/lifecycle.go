@@ -0,0 +1,111 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultStopTimeout is the time a hook may take to stop when it sets no timeout
+const DefaultStopTimeout = 10 * time.Second
+
+// Hook is a component started and stopped by the Manager
+type Hook struct {
+	Name    string
+	Start   func() error                    // Starts the component without blocking, optional
+	Stop    func(ctx context.Context) error // Stops the component before ctx is done, optional
+	Timeout time.Duration                   // Time Stop may take, DefaultStopTimeout when zero
+}
+
+// Manager starts hooks in the order they are appended and stops them in reverse order, so that a component is
+// stopped before the components it depends on
+type Manager struct {
+	logger *logrus.Logger
+	hooks  []Hook
+	errs   chan error
+}
+
+// NewManager creates a new Manager instance
+func NewManager(logger *logrus.Logger) *Manager {
+	return &Manager{logger: logger, errs: make(chan error, 1)}
+}
+
+// Append adds a hook started after and stopped before the hooks already appended
+func (m *Manager) Append(hook Hook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// Fail reports a fatal error of a running component, it triggers the shutdown
+func (m *Manager) Fail(err error) {
+	select {
+	case m.errs <- err:
+	default:
+	}
+}
+
+// Run starts every hook, waits for SIGINT, SIGTERM or a failure and stops the started hooks. It returns the error
+// of a failed start or of a failed component.
+func (m *Manager) Run() error {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	for i, hook := range m.hooks {
+		if hook.Start == nil {
+			continue
+		}
+		if err := hook.Start(); err != nil {
+			err = fmt.Errorf("starting %s: %w", hook.Name, err)
+			m.logger.Error(err)
+			m.stop(m.hooks[:i])
+			return err
+		}
+	}
+
+	var err error
+	select {
+	case sig := <-quit:
+		m.logger.Infof("Received %s, shutting down", sig)
+	case err = <-m.errs:
+		m.logger.Errorf("Shutting down after failure: %v", err)
+	}
+	m.stop(m.hooks)
+	return err
+}
+
+// stop stops hooks in reverse order, each within its timeout. A hook that does not stop in time is left behind so
+// that the next ones still stop.
+func (m *Manager) stop(hooks []Hook) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if hook.Stop == nil {
+			continue
+		}
+		timeout := hook.Timeout
+		if timeout <= 0 {
+			timeout = DefaultStopTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		done := make(chan error, 1)
+		go func() {
+			done <- hook.Stop(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				m.logger.Errorf("Unable to stop %s: %v", hook.Name, err)
+			} else {
+				m.logger.Infof("Stopped %s", hook.Name)
+			}
+		case <-ctx.Done():
+			m.logger.Errorf("Unable to stop %s within %s", hook.Name, timeout)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID, it is added to the entries logged with it
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, empty when there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHook adds the request ID and the trace of the entry context to the entry
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if id := RequestID(entry.Context); id != "" {
+		entry.Data["request_id"] = id
+	}
+	if span := trace.SpanContextFromContext(entry.Context); span.IsValid() {
+		entry.Data["trace_id"] = span.TraceID().String()
+		entry.Data["span_id"] = span.SpanID().String()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+)
+
+// Middleware carries the request ID in the request context, so that entries logged with it are correlated, and
+// logs every request once it is served. It runs after apierror.RequestIDMiddleware.
+func Middleware(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), apierror.RequestID(c)))
+		ctx := c.Request.Context()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		entry := logger.WithContext(ctx).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"route":      route,
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"bytes":      c.Writer.Size(),
+		})
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			entry.Error("Request served")
+		case status >= http.StatusBadRequest:
+			entry.Warn("Request served")
+		default:
+			entry.Info("Request served")
+		}
+	}
+}
+
+// ConfigureGin routes the output of gin to logger and leaves the gin debug mode unless logger logs at debug level
+func ConfigureGin(logger *logrus.Logger) {
+	if !logger.IsLevelEnabled(logrus.DebugLevel) {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	gin.DefaultWriter = logger.WriterLevel(logrus.DebugLevel)
+	gin.DefaultErrorWriter = logger.WriterLevel(logrus.ErrorLevel)
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Output formats of the logger
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultLevel  = logrus.InfoLevel
+	DefaultFormat = FormatText
+)
+
+// Config logging configuration
+type Config struct {
+	Level  string // logrus level name, e.g. debug or info
+	Format string // text or json
+}
+
+// New creates the logger shared by every component. Entries logged with a context carry its request ID and trace,
+// registered secrets are redacted from every entry.
+func New(cfg Config) (*logrus.Logger, error) {
+	level := DefaultLevel
+	if cfg.Level != "" {
+		parsed, err := logrus.ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, fmt.Errorf("logging: %w", err)
+		}
+		level = parsed
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(level)
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case FormatJSON:
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q", cfg.Format)
+	}
+	logger.AddHook(contextHook{})
+	logger.AddHook(redactHook{})
+	return logger, nil
+}
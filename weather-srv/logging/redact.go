@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Redacted replaces secrets in log entries
+	Redacted = "[REDACTED]"
+	// minSecretLength is the length below which secrets are not redacted, they would redact ordinary words
+	minSecretLength = 8
+)
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret makes every logger created by New redact secret, e.g. an API key appended to a provider URL
+func RegisterSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Redact replaces the registered secrets in s
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
+
+// RedactURL redacts the registered secrets, the password and the query values of raw
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Redact(raw)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if u.RawQuery != "" {
+		keys := []string{}
+		for key := range u.Query() {
+			keys = append(keys, url.QueryEscape(key)+"="+Redacted)
+		}
+		sort.Strings(keys)
+		u.RawQuery = strings.Join(keys, "&")
+	}
+	return Redact(u.String())
+}
+
+// redactHook redacts the registered secrets from the message and the string and error fields of an entry
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = Redact(v)
+		case error:
+			entry.Data[key] = Redact(v.Error())
+		}
+	}
+	return nil
+}
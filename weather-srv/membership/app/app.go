@@ -2,85 +2,224 @@ package app
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"sync"
 	"time"
 
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/lifecycle"
+	"github.com/wankhede04/blockswap.weather/weather-srv/logging"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"github.com/wankhede04/blockswap.weather/weather-srv/openapi"
+	"github.com/wankhede04/blockswap.weather/weather-srv/rpc"
+	"github.com/wankhede04/blockswap.weather/weather-srv/tracing"
 	weatherService "github.com/wankhede04/blockswap.weather/weather-srv/weather-service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
+// ShutdownConfig timeouts of the shutdown steps
+type ShutdownConfig struct {
+	HTTPDrainTimeout time.Duration // Time in-flight HTTP requests have to finish
+	GRPCDrainTimeout time.Duration // Time in-flight gRPC calls have to finish
+	StopTimeout      time.Duration // Time the watcher, background jobs and database each have to stop
+}
+
 // App ...
 type App struct {
 	logger         *logrus.Logger
 	engine         *gin.Engine
 	server         *http.Server
+	grpcAddr       string
+	grpcServer     *grpc.Server
 	weatherservice *weatherService.WeatherService
+	shutdown       ShutdownConfig
+	lifecycle      *lifecycle.Manager
 }
 
-// NewApp is initializes the app
-func NewApp(logger *logrus.Logger, addr string, weatherservice *weatherService.WeatherService) *App {
-	r := gin.Default()
+// NewApp is initializes the app and registers its routes, the gRPC server is only started when grpcAddr is set
+func NewApp(logger *logrus.Logger, addr string, grpcAddr string, trustedProxies []string, shutdown ShutdownConfig, weatherservice *weatherService.WeatherService) *App {
+	logging.ConfigureGin(logger)
+	r := gin.New()
+	// The client IP throttled and audited is only taken from X-Forwarded-For behind a trusted proxy
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		logger.Panicf("Invalid trusted proxies %s", err.Error())
+	}
+	// Panics are answered with the error envelope, every request gets an ID before anything can fail.
+	// The server span comes first so that every middleware runs inside the trace of the request, and every entry
+	// logged with the request context carries its request ID.
+	r.Use(tracing.Middleware(), apierror.RequestIDMiddleware(), logging.Middleware(logger), metrics.Middleware(), gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		logger.WithContext(c.Request.Context()).Errorf("Panic serving %s %s: %v", c.Request.Method, c.Request.URL.Path, recovered)
+		apierror.Abort(c, http.StatusInternalServerError, apierror.CodeInternal, apierror.MessageInternal, nil)
+	}))
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(apierror.NoRoute)
+	r.NoMethod(apierror.NoMethod)
+
 	srv := &http.Server{
 		Addr:    addr,
 		Handler: r,
 	}
-	return &App{logger: logger, engine: r, server: srv, weatherservice: weatherservice}
+	a := &App{
+		logger:         logger,
+		engine:         r,
+		server:         srv,
+		grpcAddr:       grpcAddr,
+		grpcServer:     rpc.NewServer(logger, weatherservice),
+		weatherservice: weatherservice,
+		shutdown:       shutdown,
+		lifecycle:      lifecycle.NewManager(logger),
+	}
+	// Routes are complete before the server accepts its first request
+	a.registerRoutes()
+	return a
 }
 
-// Run the app on it's router
-// Run the app on its router
-func (a *App) Run() {
-	// Create a wait group to wait for goroutines to finish
-	var wg sync.WaitGroup
-	wg.Add(3)
+// registerRoutes registers the versioned API, the admin API, the metrics and the probes
+func (a *App) registerRoutes() {
+	// Validate requests against the OpenAPI document before any route middleware runs
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		a.logger.Panicf("Unable to load OpenAPI document %s", err.Error())
+	}
+
+	// Every route is versioned, breaking changes go to a new group. Clients are throttled and bodies limited before
+	// anything parses them or recovers a signature, the metrics and the probes are not throttled.
+	v1 := a.engine.Group("/v1",
+		tracing.Handler("PreAuthMiddleware", a.weatherservice.PreAuthMiddleware()),
+		tracing.Handler("OpenAPIValidator", validator.Middleware()),
+	)
+	v1.POST("/report-weather",
+		tracing.Handler("ReportConcurrencyMiddleware", a.weatherservice.ReportConcurrencyMiddleware()),
+		tracing.Handler("IdempotencyMiddleware", a.weatherservice.IdempotencyMiddleware()),
+		tracing.Handler("AuthenticateMiddleware", a.weatherservice.AuthenticateMiddleware()),
+		tracing.Handler("RateLimitMiddleware", a.weatherservice.RateLimitMiddleware()),
+		tracing.Handler("ReportWeatherHandler", a.weatherservice.ReportWeatherHandler),
+	)
+	v1.POST("/report-weather/batch", tracing.Handler("ReportConcurrencyMiddleware", a.weatherservice.ReportConcurrencyMiddleware()), a.weatherservice.ReportWeatherBatchHandler)
+	v1.GET("/eip712", a.weatherservice.EIP712Handler)
+	v1.GET("/reports/stream", a.weatherservice.ReportStreamHandler)
+	v1.GET("/reports/:id/proof", a.weatherservice.MerkleProofHandler)
+	v1.GET("/merkle/epochs/:epoch", a.weatherservice.MerkleEpochHandler)
+	v1.GET("/observations", a.weatherservice.ObservationsHandler)
+	v1.GET("/members", tracing.Handler("AdminMiddleware", a.weatherservice.AdminMiddleware()), a.weatherservice.MembersHandler)
+	v1.GET("/members/:address", a.weatherservice.MemberHandler)
+	v1.GET("/members/:address/reputation", a.weatherservice.ReputationHandler)
+
+	v1.GET("/auth/nonce", a.weatherservice.AuthNonceHandler)
+	v1.POST("/auth/verify", a.weatherservice.AuthVerifyHandler)
+	v1.POST("/auth/refresh", a.weatherservice.AuthRefreshHandler)
+	v1.POST("/auth/logout", a.weatherservice.AuthLogoutHandler)
+
+	me := v1.Group("/me", tracing.Handler("SessionMiddleware", a.weatherservice.SessionMiddleware()))
+	me.GET("", a.weatherservice.MeHandler)
+	me.GET("/reports", a.weatherservice.MyReportsHandler)
+
+	v1.GET("/openapi.json", openapi.DocumentHandler)
+	v1.GET("/docs", openapi.DocsHandler)
+	v1.GET("/docs/assets/:file", openapi.DocsAssetHandler)
+
+	admin := v1.Group("/admin", tracing.Handler("AdminMiddleware", a.weatherservice.AdminMiddleware()))
+	admin.POST("/webhooks", a.weatherservice.CreateWebhookHandler)
+	admin.GET("/webhooks", a.weatherservice.ListWebhooksHandler)
+	admin.DELETE("/webhooks/:id", a.weatherservice.DeleteWebhookHandler)
+	admin.GET("/webhooks/:id/deliveries", a.weatherservice.ListWebhookDeliveriesHandler)
+	admin.PATCH("/members/:address", a.weatherservice.UpdateMemberOverridesHandler)
+	admin.PUT("/members/:address/suspension", a.weatherservice.SuspendMemberHandler)
+	admin.DELETE("/members/:address/suspension", a.weatherservice.LiftSuspensionHandler)
+	admin.GET("/audit-log", a.weatherservice.AuditLogHandler)
+	admin.GET("/throttle", a.weatherservice.ThrottleStatsHandler)
+	admin.DELETE("/throttle/bans/:ip", a.weatherservice.UnbanClientHandler)
+
+	// Prometheus and the Kubernetes probes use unversioned paths
+	a.engine.GET("/metrics", metrics.Handler())
+	a.engine.GET("/healthz", a.weatherservice.HealthzHandler)
+	a.engine.GET("/readyz", a.weatherservice.ReadyzHandler)
+}
 
-	// Start the report-weather handler in a goroutine
+// Run starts the app and blocks until SIGINT, SIGTERM or a server failure. Components are stopped in reverse order
+// of their start: report streams are disconnected, in-flight HTTP requests and gRPC calls drain, the watcher and
+// background jobs stop, then the connection pool and the database close.
+func (a *App) Run() error {
+	a.lifecycle.Append(lifecycle.Hook{
+		Name:    "database",
+		Stop:    func(context.Context) error { return a.weatherservice.Database.Close() },
+		Timeout: a.shutdown.StopTimeout,
+	})
+	a.lifecycle.Append(lifecycle.Hook{
+		Name:    "weather service",
+		Start:   func() error { a.weatherservice.Run(); return nil },
+		Stop:    func(context.Context) error { a.weatherservice.Stop(); return nil },
+		Timeout: a.shutdown.StopTimeout,
+	})
+	if a.grpcAddr != "" {
+		a.lifecycle.Append(lifecycle.Hook{Name: "gRPC server", Start: a.startGRPC, Stop: a.stopGRPC, Timeout: a.shutdown.GRPCDrainTimeout})
+	}
+	a.lifecycle.Append(lifecycle.Hook{Name: "HTTP server", Start: a.startHTTP, Stop: a.stopHTTP, Timeout: a.shutdown.HTTPDrainTimeout})
+	a.lifecycle.Append(lifecycle.Hook{
+		Name: "report streams",
+		Stop: func(context.Context) error { a.weatherservice.CloseStreams(); return nil },
+	})
+
+	a.logger.Infof("Weather Service has started. Press ctrl + C to exit.")
+	err := a.lifecycle.Run()
+	a.logger.Infoln("Weather Service has stopped")
+	return err
+}
+
+// startHTTP listens on the HTTP address, so that a taken address fails the start, and serves in a goroutine
+func (a *App) startHTTP() error {
+	lis, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return err
+	}
 	go func() {
-		defer wg.Done()
-		a.engine.POST("/report-weather", a.weatherservice.AuthenticateMiddleware(), a.weatherservice.RateLimitMiddleware(), a.weatherservice.ReportWeatherHandler)
+		if err := a.server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			a.lifecycle.Fail(fmt.Errorf("HTTP server: %w", err))
+		}
 	}()
+	return nil
+}
 
-	// Start the server in a goroutine
+// stopHTTP stops accepting requests and waits for the in-flight ones, the remaining connections are closed when ctx
+// is done
+func (a *App) stopHTTP(ctx context.Context) error {
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.server.Close()
+		return err
+	}
+	return nil
+}
+
+// startGRPC listens on the gRPC address and serves in a goroutine
+func (a *App) startGRPC() error {
+	lis, err := net.Listen("tcp", a.grpcAddr)
+	if err != nil {
+		return err
+	}
 	go func() {
-		defer wg.Done()
-		if err := a.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		if err := a.grpcServer.Serve(lis); err != nil {
+			a.lifecycle.Fail(fmt.Errorf("gRPC server: %w", err))
 		}
 	}()
+	return nil
+}
 
-	// Start the weather service in a goroutine
+// stopGRPC stops accepting calls and waits for the in-flight ones, the remaining calls are cancelled when ctx is done
+func (a *App) stopGRPC(ctx context.Context) error {
+	done := make(chan struct{})
 	go func() {
-		defer wg.Done()
-		a.weatherservice.Run()
+		a.grpcServer.GracefulStop()
+		close(done)
 	}()
-
-	a.logger.Infof("Weather Service has started. Press ctrl + C to exit.")
-
-	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
-
-	// Create a context with a timeout for graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Shutdown the server
-	if err := a.server.Shutdown(ctx); err != nil {
-		a.logger.Errorf("Server shutdown error: %v", err)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		a.grpcServer.Stop()
+		return ctx.Err()
 	}
-
-	// Close the weather service
-	a.weatherservice.Close()
-
-	// Wait for goroutines to finish
-	wg.Wait()
-
-	a.logger.Infoln("Weather Service has stopped")
 }
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/wankhede04/blockswap.weather/weather-srv/openapi"
+	weatherService "github.com/wankhede04/blockswap.weather/weather-srv/weather-service"
+)
+
+// ginParam matches the path params of gin routes, :name in gin is {name} in the OpenAPI document
+var ginParam = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// TestRoutesMatchOpenAPIDocument checks that every /v1 route is documented and every documented operation is routed
+func TestRoutesMatchOpenAPIDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := &App{logger: logrus.New(), engine: gin.New(), weatherservice: &weatherService.WeatherService{}}
+	a.registerRoutes()
+
+	routed := make(map[string]bool)
+	for _, route := range a.engine.Routes() {
+		if !strings.HasPrefix(route.Path, "/v1/") {
+			continue // Metrics and probes are not part of the versioned API
+		}
+		path := ginParam.ReplaceAllString(strings.TrimPrefix(route.Path, "/v1"), "{$1}")
+		routed[route.Method+" "+path] = true
+	}
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Document, &doc); err != nil {
+		t.Fatalf("decoding OpenAPI document: %v", err)
+	}
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item {
+			switch upper := strings.ToUpper(method); upper {
+			case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+				documented[upper+" "+path] = true
+			}
+		}
+	}
+
+	for _, op := range sorted(routed) {
+		if !documented[op] {
+			t.Errorf("route %s is missing from openapi.json", op)
+		}
+	}
+	for _, op := range sorted(documented) {
+		if !routed[op] {
+			t.Errorf("operation %s of openapi.json is not routed", op)
+		}
+	}
+}
+
+func sorted(ops map[string]bool) []string {
+	keys := make([]string, 0, len(ops))
+	for op := range ops {
+		keys = append(keys, op)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,159 @@
+package merkle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"gorm.io/gorm"
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultEpochLength = time.Hour
+	DefaultGrace       = 30 * time.Second
+	DefaultInterval    = 30 * time.Second
+)
+
+// Config holds the Merkle commitment settings
+type Config struct {
+	EpochLength time.Duration // Length of an epoch, reports are grouped by server timestamp
+	Grace       time.Duration // Delay after the end of an epoch before it is committed, covers in-flight commits
+	Interval    time.Duration // Interval between two scans for closed epochs
+}
+
+// Leaves returns the leaves of the reports, in the given order
+func Leaves(reports []db.ReportLeaf) []common.Hash {
+	leaves := make([]common.Hash, len(reports))
+	for i, r := range reports {
+		leaves[i] = Leaf(common.HexToHash(r.TypedDataHash), r.ServerTimestamp)
+	}
+	return leaves
+}
+
+// Committer builds and stores the Merkle root of every closed epoch that has reports
+type Committer struct {
+	DataBase *db.PostgresDataBase
+	Logger   *logrus.Logger
+	config   Config
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	wg       sync.WaitGroup // Running loop, waited for by Stop
+}
+
+// NewCommitter creates a new Committer instance, epochs are at least a second long
+func NewCommitter(database *db.PostgresDataBase, logger *logrus.Logger, cfg Config) *Committer {
+	if cfg.EpochLength < time.Second {
+		cfg.EpochLength = DefaultEpochLength
+	}
+	if cfg.Grace < 0 {
+		cfg.Grace = DefaultGrace
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	ctx, cancelFn := context.WithCancel(context.Background())
+	return &Committer{
+		DataBase: database,
+		Logger:   logger,
+		config:   cfg,
+		ctx:      ctx,
+		cancelFn: cancelFn,
+	}
+}
+
+// EpochSeconds returns the epoch length in seconds
+func (c *Committer) EpochSeconds() int64 {
+	return int64(c.config.EpochLength / time.Second)
+}
+
+// EpochOf returns the epoch of a server timestamp
+func (c *Committer) EpochOf(serverTimestamp int64) int64 {
+	return serverTimestamp / c.EpochSeconds()
+}
+
+// Run starts committing closed epochs until Stop is called
+func (c *Committer) Run() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.processEpochs()
+	}()
+}
+
+// Stop stops committing epochs. It returns once the batch being committed is done.
+func (c *Committer) Stop() {
+	c.cancelFn()
+	c.wg.Wait()
+}
+
+// processEpochs periodically commits the closed epochs
+func (c *Committer) processEpochs() {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.commitClosedEpochs(time.Now()); err != nil {
+				c.Logger.Errorf("Error committing merkle epochs: %v", err)
+			}
+		case <-c.ctx.Done():
+			c.Logger.Info("Merkle committer has stopped")
+			return
+		}
+	}
+}
+
+// commitClosedEpochs commits every epoch with reports after the last committed one that closed before now
+func (c *Committer) commitClosedEpochs(now time.Time) error {
+	length := c.EpochSeconds()
+
+	var from int64
+	last, err := db.FindLastMerkleEpoch(c.DataBase.DB)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if last != nil {
+		from = last.EndTime
+	}
+
+	for {
+		next, ok, err := db.FindNextReportTimestamp(c.DataBase.DB, from)
+		if err != nil || !ok {
+			return err
+		}
+		epoch := next / length
+		start, end := epoch*length, (epoch+1)*length
+		if now.Before(time.Unix(end, 0).Add(c.config.Grace)) {
+			return nil
+		}
+
+		reports, err := db.FindReportLeaves(c.DataBase.DB, start, end)
+		if err != nil {
+			return err
+		}
+		leaves := Leaves(reports)
+		merkleEpoch := db.MerkleEpoch{
+			Epoch:        epoch,
+			EpochSeconds: length,
+			StartTime:    start,
+			EndTime:      end,
+			Root:         Root(leaves).Hex(),
+			LeafCount:    len(reports),
+		}
+		// The leaves are stored with the root, reports committed or deleted later do not change the tree
+		committed := make([]db.MerkleLeaf, len(reports))
+		for i, r := range reports {
+			committed[i] = db.MerkleLeaf{Epoch: epoch, LeafIndex: i, WeatherReportID: r.ID, Leaf: leaves[i].Hex()}
+		}
+		if err := db.CreateMerkleEpoch(c.DataBase.DB, &merkleEpoch, committed); err != nil {
+			return err
+		}
+		c.Logger.Infof("Committed merkle epoch %d with %d reports, root %s", epoch, len(reports), merkleEpoch.Root)
+		from = end
+	}
+}
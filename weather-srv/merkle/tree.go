@@ -0,0 +1,78 @@
+package merkle
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Leaf returns the leaf of a report, keccak256(keccak256(abi.encode(typedDataHash, uint256(serverTimestamp)))).
+// Leaves are hashed twice like OpenZeppelin's StandardMerkleTree, so that no inner node of 64 bytes can pass as a leaf.
+func Leaf(typedDataHash common.Hash, serverTimestamp int64) common.Hash {
+	encoded := crypto.Keccak256(typedDataHash.Bytes(), math.U256Bytes(big.NewInt(serverTimestamp)))
+	return crypto.Keccak256Hash(encoded)
+}
+
+// hashPair hashes two nodes in sorted order, so proofs can be verified without the leaf position
+func hashPair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a.Bytes(), b.Bytes()) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256Hash(a.Bytes(), b.Bytes())
+}
+
+// levels builds every level of the tree from the leaves up to the root, a node without sibling is promoted as is
+func levels(leaves []common.Hash) [][]common.Hash {
+	tree := [][]common.Hash{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([]common.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, hashPair(level[i], level[i+1]))
+		}
+		tree = append(tree, next)
+		level = next
+	}
+	return tree
+}
+
+// Root returns the root of the tree over leaves, the zero hash when there are none
+func Root(leaves []common.Hash) common.Hash {
+	if len(leaves) == 0 {
+		return common.Hash{}
+	}
+	tree := levels(leaves)
+	return tree[len(tree)-1][0]
+}
+
+// Proof returns the sibling hashes from the leaf at index up to the root
+func Proof(leaves []common.Hash, index int) []common.Hash {
+	if index < 0 || index >= len(leaves) {
+		return nil
+	}
+	var proof []common.Hash
+	tree := levels(leaves)
+	for _, level := range tree[:len(tree)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		index /= 2
+	}
+	return proof
+}
+
+// Verify reports whether proof links leaf to root, it matches OpenZeppelin's MerkleProof.verify
+func Verify(root, leaf common.Hash, proof []common.Hash) bool {
+	computed := leaf
+	for _, sibling := range proof {
+		computed = hashPair(computed, sibling)
+	}
+	return computed == root
+}
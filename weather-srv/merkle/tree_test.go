@@ -0,0 +1,84 @@
+package merkle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testLeaves returns n distinct leaves
+func testLeaves(n int) []common.Hash {
+	leaves := make([]common.Hash, n)
+	for i := range leaves {
+		leaves[i] = Leaf(crypto.Keccak256Hash([]byte{byte(i)}), int64(1700000000+i))
+	}
+	return leaves
+}
+
+func TestLeaf(t *testing.T) {
+	typedDataHash := crypto.Keccak256Hash([]byte("report"))
+	inner := crypto.Keccak256(typedDataHash.Bytes(), math.U256Bytes(big.NewInt(1700000000)))
+	if got, want := Leaf(typedDataHash, 1700000000), crypto.Keccak256Hash(inner); got != want {
+		t.Fatalf("Leaf() = %s, want the double keccak256 %s", got, want)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	leaves := testLeaves(3)
+	tests := []struct {
+		name   string
+		leaves []common.Hash
+		want   common.Hash
+	}{
+		{name: "no leaves", leaves: nil, want: common.Hash{}},
+		{name: "single leaf", leaves: leaves[:1], want: leaves[0]},
+		{name: "two leaves", leaves: leaves[:2], want: hashPair(leaves[0], leaves[1])},
+		{name: "odd leaf promoted", leaves: leaves, want: hashPair(hashPair(leaves[0], leaves[1]), leaves[2])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Root(tt.leaves); got != tt.want {
+				t.Fatalf("Root() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProof(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		proofLen []int // Expected proof length of every leaf
+	}{
+		{name: "single leaf", count: 1, proofLen: []int{0}},
+		{name: "two leaves", count: 2, proofLen: []int{1, 1}},
+		{name: "three leaves", count: 3, proofLen: []int{2, 2, 1}},
+		{name: "four leaves", count: 4, proofLen: []int{2, 2, 2, 2}},
+		{name: "five leaves", count: 5, proofLen: []int{3, 3, 3, 3, 1}},
+		{name: "seven leaves", count: 7, proofLen: []int{3, 3, 3, 3, 3, 3, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaves := testLeaves(tt.count)
+			root := Root(leaves)
+			for i, leaf := range leaves {
+				proof := Proof(leaves, i)
+				if len(proof) != tt.proofLen[i] {
+					t.Errorf("len(Proof(%d)) = %d, want %d", i, len(proof), tt.proofLen[i])
+				}
+				if !Verify(root, leaf, proof) {
+					t.Errorf("Verify() rejected the proof of leaf %d", i)
+				}
+				if tt.count > 1 && Verify(root, leaves[(i+1)%tt.count], proof) {
+					t.Errorf("Verify() accepted the proof of leaf %d for another leaf", i)
+				}
+			}
+			if Proof(leaves, -1) != nil || Proof(leaves, tt.count) != nil {
+				t.Errorf("Proof() returned a proof for an index out of range")
+			}
+		})
+	}
+}
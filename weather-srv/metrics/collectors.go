@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wankhede04/blockswap.weather/weather-srv/concurrency"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/throttle"
+)
+
+var (
+	poolMaxOpenDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "max_open"),
+		"Connections the database connection pool opens at the same time at most.", nil, nil)
+	poolOpenDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "open"),
+		"Connections of the database connection pool open, in use or idle.", nil, nil)
+	poolInUseDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "in_use"),
+		"Connections of the database connection pool acquired and not yet released.", nil, nil)
+	poolIdleDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "idle"),
+		"Connections of the database connection pool open and not in use.", nil, nil)
+	poolWaitingDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "waiting"),
+		"Acquisitions waiting for a connection of the database connection pool.", nil, nil)
+	poolWaitsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "waits_total"),
+		"Acquisitions that waited for a connection of the database connection pool to be released.", nil, nil)
+	poolWaitSecondsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "wait_seconds_total"),
+		"Time spent waiting for a connection of the database connection pool.", nil, nil)
+	poolTimeoutsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "timeouts_total"),
+		"Acquisitions that gave up because no connection of the database connection pool was released in time.", nil, nil)
+
+	concurrencyCapacityDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "concurrency", "capacity"),
+		"Requests the concurrency limiter processes at the same time.", []string{"limiter"}, nil)
+	concurrencyInFlightDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "concurrency", "in_flight"),
+		"Requests processed under the concurrency limiter.", []string{"limiter"}, nil)
+	concurrencyQueueDepthDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "concurrency", "queue_depth"),
+		"Requests waiting for a slot of the concurrency limiter.", []string{"limiter"}, nil)
+
+	throttleActiveBansDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "throttle", "active_bans"),
+		"Client IPs currently banned after repeated invalid signatures.", nil, nil)
+	throttleBansDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "throttle", "bans_total"),
+		"Client IPs banned after repeated invalid signatures.", nil, nil)
+	throttleInvalidSignaturesDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "throttle", "invalid_signatures_total"),
+		"Invalid signatures counted towards client bans.", nil, nil)
+)
+
+// poolCollector reads the stats of the database connection pool when the metrics are scraped
+type poolCollector struct {
+	pool *db.ConnectionPool
+}
+
+// RegisterPool exports the stats of the database connection pool
+func RegisterPool(pool *db.ConnectionPool) {
+	prometheus.MustRegister(&poolCollector{pool: pool})
+}
+
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolMaxOpenDesc
+	ch <- poolOpenDesc
+	ch <- poolInUseDesc
+	ch <- poolIdleDesc
+	ch <- poolWaitingDesc
+	ch <- poolWaitsDesc
+	ch <- poolWaitSecondsDesc
+	ch <- poolTimeoutsDesc
+}
+
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := p.pool.Stats()
+	ch <- prometheus.MustNewConstMetric(poolMaxOpenDesc, prometheus.GaugeValue, float64(stats.MaxOpen))
+	ch <- prometheus.MustNewConstMetric(poolOpenDesc, prometheus.GaugeValue, float64(stats.Open))
+	ch <- prometheus.MustNewConstMetric(poolInUseDesc, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(poolWaitingDesc, prometheus.GaugeValue, float64(stats.Waiting))
+	ch <- prometheus.MustNewConstMetric(poolWaitsDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(poolWaitSecondsDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(poolTimeoutsDesc, prometheus.CounterValue, float64(stats.Timeouts))
+}
+
+// throttleCollector reads the ban stats of the client throttle when the metrics are scraped
+type throttleCollector struct {
+	limiter *throttle.Limiter
+}
+
+// RegisterThrottle exports the ban stats of the client throttle
+func RegisterThrottle(limiter *throttle.Limiter) {
+	prometheus.MustRegister(&throttleCollector{limiter: limiter})
+}
+
+func (t *throttleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- throttleActiveBansDesc
+	ch <- throttleBansDesc
+	ch <- throttleInvalidSignaturesDesc
+}
+
+func (t *throttleCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := t.limiter.Stats(time.Now())
+	ch <- prometheus.MustNewConstMetric(throttleActiveBansDesc, prometheus.GaugeValue, float64(stats.ActiveBans))
+	ch <- prometheus.MustNewConstMetric(throttleBansDesc, prometheus.CounterValue, float64(stats.BansTotal))
+	ch <- prometheus.MustNewConstMetric(throttleInvalidSignaturesDesc, prometheus.CounterValue, float64(stats.InvalidSignatures))
+}
+
+// concurrencyCollector reads the usage of a concurrency limiter when the metrics are scraped
+type concurrencyCollector struct {
+	name    string
+	limiter *concurrency.Limiter
+}
+
+// RegisterConcurrency exports the usage of a concurrency limiter labelled with its name
+func RegisterConcurrency(name string, limiter *concurrency.Limiter) {
+	prometheus.MustRegister(&concurrencyCollector{name: name, limiter: limiter})
+}
+
+func (c *concurrencyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- concurrencyCapacityDesc
+	ch <- concurrencyInFlightDesc
+	ch <- concurrencyQueueDepthDesc
+}
+
+func (c *concurrencyCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.limiter.Stats()
+	ch <- prometheus.MustNewConstMetric(concurrencyCapacityDesc, prometheus.GaugeValue, float64(stats.Capacity), c.name)
+	ch <- prometheus.MustNewConstMetric(concurrencyInFlightDesc, prometheus.GaugeValue, float64(stats.InFlight), c.name)
+	ch <- prometheus.MustNewConstMetric(concurrencyQueueDepthDesc, prometheus.GaugeValue, float64(stats.Queued), c.name)
+}
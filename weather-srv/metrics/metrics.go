@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// namespace prefixes every metric of the service
+const namespace = "weather"
+
+// unmatchedRoute labels the requests that matched no route, so that unknown paths do not create series
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latencies by method, route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	grpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC requests by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "gRPC request latencies by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// AuthFailures counts rejected authentications by reason, the error code returned to the client
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Rejected report signatures, sign-ins and access tokens by error code.",
+	}, []string{"reason"})
+
+	// RateLimitRejections counts requests rejected by the member rate limit or the client throttle, by reason
+	RateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the member rate limit (rate_limited, window_passed) or the client throttle (ip, subnet, banned, body_too_large).",
+	}, []string{"reason"})
+
+	// LoadShed counts requests rejected by a concurrency limiter, by limiter and reason
+	LoadShed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "load_shed_total",
+		Help:      "Requests rejected with 503 by a concurrency limiter because its queue was full (queue_full), no slot was freed in time (queue_timeout) or the client went away while queued (cancelled).",
+	}, []string{"limiter", "reason"})
+
+	// WatcherCursorHeight is the block height of the last contract event processed per chain
+	WatcherCursorHeight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watcher_cursor_height",
+		Help:      "Block height of the last contract event processed by the watcher.",
+	}, []string{"chain"})
+
+	// WatcherHeadLag is the number of blocks between the chain head and the watcher cursor
+	WatcherHeadLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watcher_head_lag_blocks",
+		Help:      "Blocks between the chain head and the last contract event processed by the watcher.",
+	}, []string{"chain"})
+
+	// WatcherEvents counts the contract events processed per chain, event type and result
+	WatcherEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watcher_events_total",
+		Help:      "Contract events processed by the watcher by event type and result.",
+	}, []string{"chain", "event", "result"})
+
+	// WatcherSubscriptionRenewals counts the attempts to renew the event subscription per chain and result
+	WatcherSubscriptionRenewals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watcher_subscription_renewals_total",
+		Help:      "Attempts to renew the contract event subscription by result.",
+	}, []string{"chain", "result"})
+)
+
+// Middleware records the count and latency of every request by route template, so that path params do not
+// create series
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Methods of unmatched requests are chosen by the client, they share one label value
+		method, route := c.Request.Method, c.FullPath()
+		if route == "" {
+			method, route = unmatchedRoute, unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// UnaryServerInterceptor records the count and latency of unary gRPC calls
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeGRPC(info.FullMethod, err, start)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the count and duration of gRPC streams
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeGRPC(info.FullMethod, err, start)
+		return err
+	}
+}
+
+func observeGRPC(method string, err error, start time.Time) {
+	code := status.Code(err).String()
+	grpcRequests.WithLabelValues(method, code).Inc()
+	grpcRequestDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+}
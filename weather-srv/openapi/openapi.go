@@ -0,0 +1,114 @@
+package openapi
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+)
+
+// Document is the OpenAPI 3 document of every route
+//
+//go:embed openapi.json
+var Document []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// swaggerUI holds the swagger-ui-dist assets of the documentation page, vendored at the release pinned in
+// swagger-ui/VERSION by scripts/vendor-swagger-ui.sh, so that the page loads nothing from a CDN
+//
+//go:embed swagger-ui
+var swaggerUI embed.FS
+
+// Validator validates requests against the OpenAPI document
+type Validator struct {
+	router routers.Router
+}
+
+// NewValidator loads the OpenAPI document and creates a new Validator instance
+func NewValidator() (*Validator, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(Document)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	// Match requests on the /v1 base path only, whatever host the service is deployed behind
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{router: router}, nil
+}
+
+// Middleware rejects requests whose parameters or body do not match the schema of their operation,
+// requests to routes missing from the document are passed through
+func (v *Validator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, pathParams, err := v.router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				// Authentication is enforced by the route middlewares
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeSchemaMismatch, "Request does not match schema", gin.H{"reason": validationDetails(err)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// validationDetails returns a client facing description of a validation error
+func validationDetails(err error) string {
+	var requestErr *openapi3filter.RequestError
+	if errors.As(err, &requestErr) {
+		var schemaErr *openapi3.SchemaError
+		if errors.As(requestErr.Err, &schemaErr) {
+			return schemaErr.Reason
+		}
+		return requestErr.Error()
+	}
+	return err.Error()
+}
+
+// DocumentHandler serves the OpenAPI document
+func DocumentHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", Document)
+}
+
+// DocsHandler serves the documentation UI rendering the OpenAPI document
+func DocsHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+// DocsAssetHandler serves the vendored Swagger UI assets of the documentation page
+func DocsAssetHandler(c *gin.Context) {
+	name := c.Param("file")
+	data, err := swaggerUI.ReadFile("swagger-ui/" + name)
+	if err != nil {
+		apierror.Write(c, http.StatusNotFound, apierror.CodeNotFound, "Asset not found", nil)
+		return
+	}
+	c.Data(http.StatusOK, mime.TypeByExtension(path.Ext(name)), data)
+}
@@ -0,0 +1,267 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"github.com/wankhede04/blockswap.weather/weather-srv/rpc/weatherpb"
+	"github.com/wankhede04/blockswap.weather/weather-srv/stream"
+	weatherService "github.com/wankhede04/blockswap.weather/weather-srv/weather-service"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// defaultListLimit is the page size of ListReports when no limit is given
+	defaultListLimit = 20
+	// maxListLimit is the maximum page size of ListReports
+	maxListLimit = 100
+	// errorDomain is the domain of the ErrorInfo details attached to errors
+	errorDomain = "weather.v1"
+)
+
+// Server implements the WeatherService gRPC API on top of the shared WeatherService logic
+type Server struct {
+	weatherpb.UnimplementedWeatherServiceServer
+	logger         *logrus.Logger
+	weatherservice *weatherService.WeatherService
+}
+
+// NewServer creates a gRPC server with the WeatherService API registered, messages are limited like HTTP bodies
+func NewServer(logger *logrus.Logger, weatherservice *weatherService.WeatherService) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(int(weatherservice.MaxBodyBytes())),
+		// Spans continue the trace of the incoming metadata, like the HTTP server spans
+		grpc.ChainUnaryInterceptor(otelgrpc.UnaryServerInterceptor(), metrics.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(otelgrpc.StreamServerInterceptor(), metrics.StreamServerInterceptor()),
+	)
+	weatherpb.RegisterWeatherServiceServer(srv, &Server{logger: logger, weatherservice: weatherservice})
+	return srv
+}
+
+// SubmitReport verifies, rate limits and stores a signed weather report
+func (s *Server) SubmitReport(ctx context.Context, req *weatherpb.SubmitReportRequest) (*weatherpb.SubmitReportResponse, error) {
+	// Clients are throttled before their signature is recovered, like the HTTP API
+	ip := peerIP(ctx)
+	if err := s.weatherservice.ThrottleClient(ip); err != nil {
+		return nil, s.toStatusError(err)
+	}
+	// Reports share the concurrency limit of the HTTP API
+	release, err := s.weatherservice.AcquireReportSlot(ctx)
+	if err != nil {
+		return nil, s.toStatusError(err)
+	}
+	defer release()
+
+	report, next, err := s.weatherservice.SubmitReport(ctx, weatherService.WeatherReport{
+		Address:         req.GetAddress(),
+		Report:          req.GetReport(),
+		Signature:       req.GetSignature(),
+		SignatureScheme: req.GetSignatureScheme(),
+	})
+	if err != nil {
+		if weatherService.IsInvalidSignature(err) {
+			s.weatherservice.RecordInvalidSignature(ctx, ip)
+		}
+		return nil, s.toStatusError(err)
+	}
+	return &weatherpb.SubmitReportResponse{Id: uint64(report.ID), NextWindow: toRateWindow(next)}, nil
+}
+
+// GetMember returns the stored status and activity of a member
+func (s *Server) GetMember(ctx context.Context, req *weatherpb.GetMemberRequest) (*weatherpb.Member, error) {
+	member, err := s.weatherservice.LookupMember(ctx, req.GetAddress())
+	if err != nil {
+		return nil, s.toStatusError(err)
+	}
+
+	transitions := make([]*weatherpb.Transition, len(member.RecentTransitions))
+	for i, t := range member.RecentTransitions {
+		transitions[i] = &weatherpb.Transition{
+			Event:           t.Event,
+			ChainName:       t.ChainName,
+			BlockHeight:     t.BlockHeight,
+			TransactionHash: t.TransactionHash,
+			RecordedAt:      t.RecordedAt,
+		}
+	}
+	return &weatherpb.Member{
+		Address:              member.Address,
+		Status:               member.Status,
+		ChainName:            member.ChainName,
+		RegistrationContract: member.RegistrationContract,
+		Region:               member.Region,
+		Reputation:           member.Reputation,
+		LastCall:             member.LastCall,
+		NextWindow:           toRateWindow(member.NextWindow),
+		ReportCount:          member.ReportCount,
+		ReportCount_24H:      member.ReportCount24h,
+		RecentTransitions:    transitions,
+		Suspension:           toSuspension(member.Suspension),
+	}, nil
+}
+
+// ListReports returns committed reports after a report ID, oldest first
+func (s *Server) ListReports(ctx context.Context, req *weatherpb.ListReportsRequest) (*weatherpb.ListReportsResponse, error) {
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit < 0 || limit > maxListLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid limit")
+	}
+
+	filter := stream.Filter{Address: req.GetAddress(), Region: req.GetRegion()}
+	events, err := s.weatherservice.QueryReports(ctx, uint(req.GetAfterId()), filter, limit)
+	if err != nil {
+		return nil, s.toStatusError(err)
+	}
+	reports := make([]*weatherpb.Report, len(events))
+	for i, e := range events {
+		reports[i] = toReport(e)
+	}
+	return &weatherpb.ListReportsResponse{Reports: reports}, nil
+}
+
+// StreamReports replays the reports after the last_event_id sequence and then pushes every committed report matching the filter
+func (s *Server) StreamReports(req *weatherpb.StreamReportsRequest, srv weatherpb.WeatherService_StreamReportsServer) error {
+	filter := stream.Filter{Address: req.GetAddress(), Region: req.GetRegion()}
+	sub := s.weatherservice.SubscribeReports(filter)
+	defer s.weatherservice.UnsubscribeReports(sub)
+
+	replayed := req.GetLastEventId()
+	if replayed > 0 {
+		var err error
+		replayed, err = s.weatherservice.ReplayReports(srv.Context(), filter, replayed, func(e stream.ReportEvent) error {
+			return srv.Send(toReport(e))
+		})
+		if err != nil {
+			if srv.Context().Err() != nil {
+				return nil
+			}
+			return s.toStatusError(fmt.Errorf("unable to replay reports after %d: %w", req.GetLastEventId(), err))
+		}
+	}
+
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				return status.Errorf(codes.Unavailable, "Report stream closed")
+			}
+			if e.Sequence <= replayed {
+				continue
+			}
+			if err := srv.Send(toReport(e)); err != nil {
+				return err
+			}
+		case <-srv.Context().Done():
+			return nil
+		}
+	}
+}
+
+// toStatusError maps a ReportError to the gRPC code of its HTTP status, with its code and next window as details
+func (s *Server) toStatusError(err error) error {
+	var reportErr *weatherService.ReportError
+	if !errors.As(err, &reportErr) {
+		s.logger.Errorf("gRPC request failed: %v", err)
+		return status.Errorf(codes.Internal, "Internal error")
+	}
+
+	var code codes.Code
+	switch reportErr.Status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	default:
+		s.logger.Errorf("gRPC request failed: %v", err)
+		return status.Errorf(codes.Internal, "Internal error")
+	}
+
+	st := status.New(code, reportErr.Message)
+	info := &errdetails.ErrorInfo{Domain: errorDomain, Reason: reportErr.Code, Metadata: map[string]string{}}
+	if info.Reason == "" {
+		info.Reason = strings.ToLower(strings.ReplaceAll(http.StatusText(reportErr.Status), " ", "_"))
+	}
+	if next := reportErr.NextWindow; next != nil {
+		info.Metadata["next_window_open"] = strconv.FormatInt(next.Open, 10)
+		info.Metadata["next_window_close"] = strconv.FormatInt(next.Close, 10)
+		retryAfter := time.Until(time.Unix(next.Open, 0))
+		if retryAfter < 0 {
+			retryAfter = 0
+		}
+		if withDetails, err := st.WithDetails(info, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+			return withDetails.Err()
+		}
+		return st.Err()
+	}
+	if reportErr.RetryAfter > 0 {
+		retryAfter := durationpb.New(time.Duration(reportErr.RetryAfter) * time.Second)
+		if withDetails, err := st.WithDetails(info, &errdetails.RetryInfo{RetryDelay: retryAfter}); err == nil {
+			return withDetails.Err()
+		}
+		return st.Err()
+	}
+	if withDetails, err := st.WithDetails(info); err == nil {
+		return withDetails.Err()
+	}
+	return st.Err()
+}
+
+// peerIP returns the IP of the gRPC client, nil when it is not a TCP peer
+func peerIP(ctx context.Context) net.IP {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+func toRateWindow(w weatherService.RateWindow) *weatherpb.RateWindow {
+	return &weatherpb.RateWindow{Open: w.Open, Close: w.Close}
+}
+
+func toSuspension(s *weatherService.SuspensionResponse) *weatherpb.Suspension {
+	if s == nil {
+		return nil
+	}
+	return &weatherpb.Suspension{Reason: s.Reason, Until: s.Until}
+}
+
+func toReport(e stream.ReportEvent) *weatherpb.Report {
+	return &weatherpb.Report{
+		Id:        uint64(e.ID),
+		Sequence:  e.Sequence,
+		Address:   e.Address,
+		Region:    e.Region,
+		Report:    e.Report,
+		CreatedAt: e.CreatedAt.Unix(),
+	}
+}
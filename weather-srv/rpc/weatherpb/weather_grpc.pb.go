@@ -0,0 +1,256 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: weather.proto
+
+package weatherpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	WeatherService_SubmitReport_FullMethodName  = "/weather.v1.WeatherService/SubmitReport"
+	WeatherService_GetMember_FullMethodName     = "/weather.v1.WeatherService/GetMember"
+	WeatherService_ListReports_FullMethodName   = "/weather.v1.WeatherService/ListReports"
+	WeatherService_StreamReports_FullMethodName = "/weather.v1.WeatherService/StreamReports"
+)
+
+// WeatherServiceClient is the client API for WeatherService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WeatherServiceClient interface {
+	// SubmitReport verifies, rate limits and stores a signed weather report.
+	SubmitReport(ctx context.Context, in *SubmitReportRequest, opts ...grpc.CallOption) (*SubmitReportResponse, error)
+	// GetMember returns the stored status and activity of a member.
+	GetMember(ctx context.Context, in *GetMemberRequest, opts ...grpc.CallOption) (*Member, error)
+	// ListReports returns committed reports after a report ID, oldest first.
+	ListReports(ctx context.Context, in *ListReportsRequest, opts ...grpc.CallOption) (*ListReportsResponse, error)
+	// StreamReports replays the reports after the last_event_id sequence and then pushes every committed report.
+	StreamReports(ctx context.Context, in *StreamReportsRequest, opts ...grpc.CallOption) (WeatherService_StreamReportsClient, error)
+}
+
+type weatherServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWeatherServiceClient(cc grpc.ClientConnInterface) WeatherServiceClient {
+	return &weatherServiceClient{cc}
+}
+
+func (c *weatherServiceClient) SubmitReport(ctx context.Context, in *SubmitReportRequest, opts ...grpc.CallOption) (*SubmitReportResponse, error) {
+	out := new(SubmitReportResponse)
+	err := c.cc.Invoke(ctx, WeatherService_SubmitReport_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) GetMember(ctx context.Context, in *GetMemberRequest, opts ...grpc.CallOption) (*Member, error) {
+	out := new(Member)
+	err := c.cc.Invoke(ctx, WeatherService_GetMember_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) ListReports(ctx context.Context, in *ListReportsRequest, opts ...grpc.CallOption) (*ListReportsResponse, error) {
+	out := new(ListReportsResponse)
+	err := c.cc.Invoke(ctx, WeatherService_ListReports_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) StreamReports(ctx context.Context, in *StreamReportsRequest, opts ...grpc.CallOption) (WeatherService_StreamReportsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WeatherService_ServiceDesc.Streams[0], WeatherService_StreamReports_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &weatherServiceStreamReportsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WeatherService_StreamReportsClient interface {
+	Recv() (*Report, error)
+	grpc.ClientStream
+}
+
+type weatherServiceStreamReportsClient struct {
+	grpc.ClientStream
+}
+
+func (x *weatherServiceStreamReportsClient) Recv() (*Report, error) {
+	m := new(Report)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WeatherServiceServer is the server API for WeatherService service.
+// All implementations must embed UnimplementedWeatherServiceServer
+// for forward compatibility
+type WeatherServiceServer interface {
+	// SubmitReport verifies, rate limits and stores a signed weather report.
+	SubmitReport(context.Context, *SubmitReportRequest) (*SubmitReportResponse, error)
+	// GetMember returns the stored status and activity of a member.
+	GetMember(context.Context, *GetMemberRequest) (*Member, error)
+	// ListReports returns committed reports after a report ID, oldest first.
+	ListReports(context.Context, *ListReportsRequest) (*ListReportsResponse, error)
+	// StreamReports replays the reports after the last_event_id sequence and then pushes every committed report.
+	StreamReports(*StreamReportsRequest, WeatherService_StreamReportsServer) error
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+// UnimplementedWeatherServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWeatherServiceServer struct {
+}
+
+func (UnimplementedWeatherServiceServer) SubmitReport(context.Context, *SubmitReportRequest) (*SubmitReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitReport not implemented")
+}
+func (UnimplementedWeatherServiceServer) GetMember(context.Context, *GetMemberRequest) (*Member, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMember not implemented")
+}
+func (UnimplementedWeatherServiceServer) ListReports(context.Context, *ListReportsRequest) (*ListReportsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListReports not implemented")
+}
+func (UnimplementedWeatherServiceServer) StreamReports(*StreamReportsRequest, WeatherService_StreamReportsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamReports not implemented")
+}
+func (UnimplementedWeatherServiceServer) mustEmbedUnimplementedWeatherServiceServer() {}
+
+// UnsafeWeatherServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WeatherServiceServer will
+// result in compilation errors.
+type UnsafeWeatherServiceServer interface {
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+func RegisterWeatherServiceServer(s grpc.ServiceRegistrar, srv WeatherServiceServer) {
+	s.RegisterService(&WeatherService_ServiceDesc, srv)
+}
+
+func _WeatherService_SubmitReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).SubmitReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_SubmitReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).SubmitReport(ctx, req.(*SubmitReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_GetMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetMember(ctx, req.(*GetMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_ListReports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListReportsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).ListReports(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_ListReports_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).ListReports(ctx, req.(*ListReportsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_StreamReports_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamReportsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WeatherServiceServer).StreamReports(m, &weatherServiceStreamReportsServer{stream})
+}
+
+type WeatherService_StreamReportsServer interface {
+	Send(*Report) error
+	grpc.ServerStream
+}
+
+type weatherServiceStreamReportsServer struct {
+	grpc.ServerStream
+}
+
+func (x *weatherServiceStreamReportsServer) Send(m *Report) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// WeatherService_ServiceDesc is the grpc.ServiceDesc for WeatherService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WeatherService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.WeatherService",
+	HandlerType: (*WeatherServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitReport",
+			Handler:    _WeatherService_SubmitReport_Handler,
+		},
+		{
+			MethodName: "GetMember",
+			Handler:    _WeatherService_GetMember_Handler,
+		},
+		{
+			MethodName: "ListReports",
+			Handler:    _WeatherService_ListReports_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReports",
+			Handler:       _WeatherService_StreamReports_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "weather.proto",
+}
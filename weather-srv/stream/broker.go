@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// subscriberBuffer is the number of events buffered per subscriber before it is dropped as too slow
+const subscriberBuffer = 64
+
+// ReportEvent is a committed weather report pushed to stream subscribers
+type ReportEvent struct {
+	ID        uint      `json:"id"`
+	Sequence  uint64    `json:"sequence,omitempty"` // Position in commit order, streams resume after it
+	Address   string    `json:"address"`
+	Region    string    `json:"region"`
+	Report    string    `json:"report"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Filter selects the events a subscriber receives, empty fields match everything
+type Filter struct {
+	Address string
+	Region  string
+}
+
+// Match reports whether the event passes the filter
+func (f Filter) Match(e ReportEvent) bool {
+	if f.Address != "" && !strings.EqualFold(f.Address, e.Address) {
+		return false
+	}
+	if f.Region != "" && !strings.EqualFold(f.Region, e.Region) {
+		return false
+	}
+	return true
+}
+
+// Subscriber receives the events matching its filter on Events until it is closed
+type Subscriber struct {
+	Events chan ReportEvent
+	filter Filter
+	once   sync.Once
+}
+
+func (s *Subscriber) close() {
+	s.once.Do(func() { close(s.Events) })
+}
+
+// Broker fans committed reports out to stream subscribers
+type Broker struct {
+	logger      *logrus.Logger
+	mu          sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+}
+
+// NewBroker creates a new Broker instance
+func NewBroker(logger *logrus.Logger) *Broker {
+	return &Broker{
+		logger:      logger,
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber for the events matching filter
+func (b *Broker) Subscribe(filter Filter) *Subscriber {
+	sub := &Subscriber{Events: make(chan ReportEvent, subscriberBuffer), filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes the subscriber and closes its channel
+func (b *Broker) Unsubscribe(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, sub)
+	sub.close()
+}
+
+// Publish sends the event to every matching subscriber, subscribers that cannot keep up are dropped
+func (b *Broker) Publish(e ReportEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.Events <- e:
+		default:
+			b.logger.Warnf("Dropping slow report stream subscriber at sequence %d", e.Sequence)
+			delete(b.subscribers, sub)
+			sub.close()
+		}
+	}
+}
+
+// Close closes every subscriber
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		sub.close()
+	}
+}
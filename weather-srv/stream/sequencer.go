@@ -0,0 +1,160 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultInterval  = time.Second
+	DefaultBatchSize = 500
+)
+
+// Config holds the report sequencing settings
+type Config struct {
+	Interval  time.Duration // Interval between two sequencing runs, reports committed by this instance are picked up right away
+	BatchSize int           // Maximum number of reports numbered or published per query
+}
+
+// Sequencer numbers committed reports in the order they become visible and publishes them to the broker in that
+// order. Report IDs are taken on insert, so a slow transaction can commit a report after one with a higher ID;
+// stream sequences are only given to committed reports, under a lock shared by every instance, so a client
+// resuming after a sequence never misses a report. Every instance publishes the reports of all instances.
+type Sequencer struct {
+	DataBase *db.PostgresDataBase
+	Logger   *logrus.Logger
+	broker   *Broker
+	config   Config
+	notify   chan struct{}
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	wg       sync.WaitGroup // Running loop, waited for by Stop
+}
+
+// NewSequencer creates a new Sequencer instance publishing to broker
+func NewSequencer(database *db.PostgresDataBase, logger *logrus.Logger, broker *Broker, cfg Config) *Sequencer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	ctx, cancelFn := context.WithCancel(context.Background())
+	return &Sequencer{
+		DataBase: database,
+		Logger:   logger,
+		broker:   broker,
+		config:   cfg,
+		notify:   make(chan struct{}, 1),
+		ctx:      ctx,
+		cancelFn: cancelFn,
+	}
+}
+
+// Run starts numbering and publishing reports until Stop is called
+func (s *Sequencer) Run() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.processReports()
+	}()
+}
+
+// Stop stops numbering reports, the reports committed meanwhile are numbered by the other instances or on the next start.
+// It returns once the running batch is done.
+func (s *Sequencer) Stop() {
+	s.cancelFn()
+	s.wg.Wait()
+}
+
+// Notify wakes the sequencer up after a report is committed, without waiting for the next interval
+func (s *Sequencer) Notify() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// processReports numbers and publishes the committed reports on every interval or notification
+func (s *Sequencer) processReports() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	// Reports numbered before the start were published by the instances running then
+	published, started := uint64(0), false
+	s.Notify() // The first run does not wait for the interval
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.notify:
+		case <-s.ctx.Done():
+			s.Logger.Info("Report sequencer has stopped")
+			return
+		}
+
+		if !started {
+			last, err := db.MaxStreamSequence(s.DataBase.DB)
+			if err != nil {
+				s.Logger.Errorf("Error reading the last report sequence: %v", err)
+				continue
+			}
+			published, started = last, true
+		}
+		if err := s.numberReports(); err != nil {
+			s.Logger.Errorf("Error numbering reports: %v", err)
+		}
+		last, err := s.publishReports(published)
+		if err != nil {
+			s.Logger.Errorf("Error publishing reports after sequence %d: %v", published, err)
+		}
+		published = last
+	}
+}
+
+// numberReports numbers the committed reports without a sequence, in batches while there is a backlog
+func (s *Sequencer) numberReports() error {
+	for {
+		numbered, err := db.AssignStreamSequences(s.DataBase.DB, s.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		if numbered < int64(s.config.BatchSize) || s.ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// publishReports publishes the reports numbered after the published sequence, in sequence order, and returns the
+// sequence of the last one published
+func (s *Sequencer) publishReports(published uint64) (uint64, error) {
+	for {
+		records, err := db.FindReportsAfterSequence(s.DataBase.DB, published, "", "", s.config.BatchSize)
+		if err != nil {
+			return published, err
+		}
+		for _, r := range records {
+			s.broker.Publish(EventFromRecord(r))
+			published = r.StreamSequence
+		}
+		if len(records) < s.config.BatchSize || s.ctx.Err() != nil {
+			return published, nil
+		}
+	}
+}
+
+// EventFromRecord converts a stored report to the event sent to subscribers
+func EventFromRecord(r db.ReportRecord) ReportEvent {
+	return ReportEvent{
+		ID:        r.ID,
+		Sequence:  r.StreamSequence,
+		Address:   r.Address,
+		Region:    r.Region,
+		Report:    r.Report,
+		CreatedAt: r.CreatedAt,
+	}
+}
@@ -0,0 +1,280 @@
+package throttle
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultIPv4Prefix   = 24
+	DefaultIPv6Prefix   = 64
+	DefaultBanWindow    = 10 * time.Minute
+	DefaultBanDuration  = 15 * time.Minute
+	DefaultMaxBodyBytes = 1 << 20
+	// idleTimeout is how long an untouched bucket or failure counter is kept
+	idleTimeout = 10 * time.Minute
+)
+
+// Config pre-authentication throttling configuration, a zero rate disables its buckets and a zero ban threshold disables bans
+type Config struct {
+	IPRate       float64       // Requests per second refilled in the bucket of a client IP
+	IPBurst      int           // Size of the bucket of a client IP
+	SubnetRate   float64       // Requests per second refilled in the bucket of a client subnet
+	SubnetBurst  int           // Size of the bucket of a client subnet
+	IPv4Prefix   int           // Prefix length grouping IPv4 clients into a subnet
+	IPv6Prefix   int           // Prefix length grouping IPv6 clients into a subnet
+	BanThreshold int           // Invalid signatures within BanWindow that ban a client IP
+	BanWindow    time.Duration // Window counting invalid signatures
+	BanDuration  time.Duration // How long a client IP stays banned
+	MaxBodyBytes int64         // Maximum request body size
+}
+
+// Decision is the outcome of Allow
+type Decision int
+
+const (
+	Allowed       Decision = iota // The request may go on
+	Banned                        // The client IP is banned for invalid signatures
+	IPLimited                     // The bucket of the client IP is empty
+	SubnetLimited                 // The bucket of the client subnet is empty
+)
+
+// Stats are the counters of a Limiter since it was created
+type Stats struct {
+	ActiveBans        int    `json:"active_bans"`
+	BansTotal         uint64 `json:"bans_total"`
+	BannedRejected    uint64 `json:"banned_rejected"`
+	IPLimited         uint64 `json:"ip_limited"`
+	SubnetLimited     uint64 `json:"subnet_limited"`
+	BodyTooLarge      uint64 `json:"body_too_large"`
+	InvalidSignatures uint64 `json:"invalid_signatures"`
+}
+
+// Ban is a banned client IP
+type Ban struct {
+	IP    string `json:"ip"`
+	Until int64  `json:"until"` // Unix time the ban expires
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type failures struct {
+	count int
+	start time.Time
+}
+
+// Limiter holds the token buckets, invalid signature counters and bans of the clients in memory
+type Limiter struct {
+	config Config
+
+	mu        sync.Mutex
+	ips       map[string]*bucket
+	subnets   map[string]*bucket
+	failures  map[string]*failures
+	bans      map[string]time.Time
+	stats     Stats
+	lastPrune time.Time
+}
+
+// NewLimiter creates a new Limiter instance
+func NewLimiter(cfg Config) *Limiter {
+	if cfg.IPv4Prefix <= 0 || cfg.IPv4Prefix > 32 {
+		cfg.IPv4Prefix = DefaultIPv4Prefix
+	}
+	if cfg.IPv6Prefix <= 0 || cfg.IPv6Prefix > 128 {
+		cfg.IPv6Prefix = DefaultIPv6Prefix
+	}
+	if cfg.BanWindow <= 0 {
+		cfg.BanWindow = DefaultBanWindow
+	}
+	if cfg.BanDuration <= 0 {
+		cfg.BanDuration = DefaultBanDuration
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if cfg.IPBurst < 1 {
+		cfg.IPBurst = 1
+	}
+	if cfg.SubnetBurst < 1 {
+		cfg.SubnetBurst = 1
+	}
+	return &Limiter{
+		config:   cfg,
+		ips:      map[string]*bucket{},
+		subnets:  map[string]*bucket{},
+		failures: map[string]*failures{},
+		bans:     map[string]time.Time{},
+	}
+}
+
+// Config returns the configuration with defaults applied
+func (l *Limiter) Config() Config {
+	return l.config
+}
+
+// Allow takes a token from the buckets of ip and its subnet. Banned clients are rejected without taking tokens,
+// retryAfter is when the client may try again for rejected requests.
+func (l *Limiter) Allow(ip net.IP, now time.Time) (decision Decision, retryAfter time.Duration) {
+	key := ip.String()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+
+	if until, ok := l.bans[key]; ok {
+		if now.Before(until) {
+			l.stats.BannedRejected++
+			return Banned, until.Sub(now)
+		}
+		delete(l.bans, key)
+	}
+
+	if l.config.IPRate > 0 {
+		if wait := take(l.ips, key, l.config.IPRate, l.config.IPBurst, now); wait > 0 {
+			l.stats.IPLimited++
+			return IPLimited, wait
+		}
+	}
+	if l.config.SubnetRate > 0 {
+		if wait := take(l.subnets, l.subnet(ip), l.config.SubnetRate, l.config.SubnetBurst, now); wait > 0 {
+			l.stats.SubnetLimited++
+			return SubnetLimited, wait
+		}
+	}
+	return Allowed, 0
+}
+
+// RecordInvalidSignature counts an invalid signature sent by ip and bans it once the threshold is reached within the
+// ban window. It returns the end of the ban when ip got banned.
+func (l *Limiter) RecordInvalidSignature(ip net.IP, now time.Time) (time.Time, bool) {
+	key := ip.String()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.InvalidSignatures++
+	if l.config.BanThreshold <= 0 {
+		return time.Time{}, false
+	}
+
+	f, ok := l.failures[key]
+	if !ok || now.Sub(f.start) >= l.config.BanWindow {
+		f = &failures{start: now}
+		l.failures[key] = f
+	}
+	f.count++
+	if f.count < l.config.BanThreshold {
+		return time.Time{}, false
+	}
+
+	delete(l.failures, key)
+	until := now.Add(l.config.BanDuration)
+	l.bans[key] = until
+	l.stats.BansTotal++
+	return until, true
+}
+
+// RecordBodyTooLarge counts a request rejected for its body size
+func (l *Limiter) RecordBodyTooLarge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.BodyTooLarge++
+}
+
+// Unban lifts the ban of ip, it reports whether ip was banned
+func (l *Limiter) Unban(ip net.IP) bool {
+	key := ip.String()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.bans[key]
+	delete(l.bans, key)
+	delete(l.failures, key)
+	return ok
+}
+
+// Bans returns the bans active at now
+func (l *Limiter) Bans(now time.Time) []Ban {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bans := []Ban{}
+	for ip, until := range l.bans {
+		if now.Before(until) {
+			bans = append(bans, Ban{IP: ip, Until: until.Unix()})
+		}
+	}
+	return bans
+}
+
+// Stats returns the counters and the number of bans active at now
+func (l *Limiter) Stats(now time.Time) Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	for _, until := range l.bans {
+		if now.Before(until) {
+			stats.ActiveBans++
+		}
+	}
+	return stats
+}
+
+// subnet returns the network of ip for the configured prefix lengths
+func (l *Limiter) subnet(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(l.config.IPv4Prefix, 32)), Mask: net.CIDRMask(l.config.IPv4Prefix, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(l.config.IPv6Prefix, 128)), Mask: net.CIDRMask(l.config.IPv6Prefix, 128)}).String()
+}
+
+// prune drops the idle buckets, stale failure counters and expired bans at most once per idle timeout
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < idleTimeout {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.ips {
+		if now.Sub(b.last) >= idleTimeout {
+			delete(l.ips, key)
+		}
+	}
+	for key, b := range l.subnets {
+		if now.Sub(b.last) >= idleTimeout {
+			delete(l.subnets, key)
+		}
+	}
+	for key, f := range l.failures {
+		if now.Sub(f.start) >= l.config.BanWindow {
+			delete(l.failures, key)
+		}
+	}
+	for key, until := range l.bans {
+		if !now.Before(until) {
+			delete(l.bans, key)
+		}
+	}
+}
+
+// take refills the bucket of key and takes a token, it returns how long to wait for a token when the bucket is empty
+func take(buckets map[string]*bucket, key string, rate float64, burst int, now time.Time) time.Duration {
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
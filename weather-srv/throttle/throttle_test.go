@@ -0,0 +1,65 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	type step struct {
+		at   time.Duration // Offset from start of the call
+		wait time.Duration // Expected wait, 0 when a token is taken
+	}
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		steps []step
+	}{
+		{
+			name: "burst then empty", rate: 1, burst: 2,
+			steps: []step{{0, 0}, {0, 0}, {0, time.Second}, {0, time.Second}},
+		},
+		{
+			name: "partial refill", rate: 1, burst: 2,
+			steps: []step{{0, 0}, {0, 0}, {500 * time.Millisecond, 500 * time.Millisecond}, {time.Second, 0}, {time.Second, time.Second}},
+		},
+		{
+			name: "refill capped at burst", rate: 1, burst: 2,
+			steps: []step{{0, 0}, {0, 0}, {time.Hour, 0}, {time.Hour, 0}, {time.Hour, time.Second}},
+		},
+		{
+			name: "fractional rate", rate: 0.5, burst: 1,
+			steps: []step{{0, 0}, {0, 2 * time.Second}, {time.Second, time.Second}, {2 * time.Second, 0}},
+		},
+		{
+			name: "clock going back does not refill", rate: 1, burst: 1,
+			steps: []step{{time.Second, 0}, {0, time.Second}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets := make(map[string]*bucket)
+			for i, s := range tt.steps {
+				if got := take(buckets, "10.0.0.1", tt.rate, tt.burst, start.Add(s.at)); got != s.wait {
+					t.Fatalf("step %d: take() = %v, want %v", i, got, s.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestTakeKeepsKeysApart(t *testing.T) {
+	buckets := make(map[string]*bucket)
+	now := time.Unix(1700000000, 0)
+	if wait := take(buckets, "a", 1, 1, now); wait != 0 {
+		t.Fatalf("take(a) = %v, want 0", wait)
+	}
+	if wait := take(buckets, "b", 1, 1, now); wait != 0 {
+		t.Fatalf("take(b) = %v, want 0 as b has its own bucket", wait)
+	}
+	if wait := take(buckets, "a", 1, 1, now); wait != time.Second {
+		t.Fatalf("take(a) = %v, want 1s", wait)
+	}
+}
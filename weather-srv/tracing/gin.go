@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// untracedPaths are polled by Prometheus and the probes, their spans would only be noise
+var untracedPaths = map[string]bool{"/metrics": true, "/healthz": true, "/readyz": true}
+
+// Middleware starts the server span of each request, continuing the trace of the incoming traceparent header
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(serviceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return !untracedPaths[r.URL.Path]
+	}))
+}
+
+// Handler wraps a middleware or handler in a span named name. The span of a middleware calling c.Next also covers
+// the handlers after it, so that their spans nest under it.
+func Handler(name string, h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent := c.Request.Context()
+		ctx, span := Start(parent, name)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		h(c)
+		c.Request = c.Request.WithContext(parent)
+
+		if c.IsAborted() {
+			span.SetAttributes(attribute.Bool("gin.aborted", true), attribute.Int("http.status_code", c.Writer.Status()))
+			if c.Writer.Status() >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(c.Writer.Status()))
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// gormSpanKey stores the span of a statement between its before and after callbacks
+const gormSpanKey = "tracing:span"
+
+// registerer is a gorm callback position, e.g. before gorm:create
+type registerer interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// InstrumentGorm registers callbacks starting a client span for every query of database. Queries run on a session
+// created with WithContext are children of the span in that context.
+func InstrumentGorm(database *gorm.DB) error {
+	cb := database.Callback()
+	operations := []struct {
+		name          string
+		before, after registerer
+	}{
+		{"create", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create")},
+		{"query", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")},
+		{"update", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update")},
+		{"delete", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete")},
+		{"row", cb.Row().Before("gorm:row"), cb.Row().After("gorm:row")},
+		{"raw", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")},
+	}
+	for _, op := range operations {
+		if err := op.before.Register("tracing:before_"+op.name, startGormSpan(op.name)); err != nil {
+			return err
+		}
+		if err := op.after.Register("tracing:after_"+op.name, endGormSpan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startGormSpan starts the span of a statement, it is named after the operation and the table
+func startGormSpan(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		name := "gorm." + operation
+		if tx.Statement.Table != "" {
+			name += " " + tx.Statement.Table
+		}
+		_, span := Tracer().Start(tx.Statement.Context, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(operation),
+			semconv.DBSQLTable(tx.Statement.Table),
+		))
+		tx.InstanceSet(gormSpanKey, span)
+	}
+}
+
+// endGormSpan ends the span of a statement with its SQL and affected rows, a missing record is not an error
+func endGormSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		semconv.DBStatement(tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	err := tx.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultServiceName = "weather-srv"
+	DefaultSampleRatio = 1.0
+)
+
+// instrumentationName names the tracer of the service spans
+const instrumentationName = "github.com/wankhede04/blockswap.weather/weather-srv"
+
+// serviceName is the configured service name, set by Init
+var serviceName = DefaultServiceName
+
+// Config OTLP tracing configuration, an empty endpoint disables the exporter
+type Config struct {
+	Endpoint    string  // host:port of the OTLP gRPC collector
+	Insecure    bool    // Whether the collector is reached without TLS, e.g. a local collector
+	ServiceName string  // service.name resource attribute of the spans
+	SampleRatio float64 // Fraction of the traces started here that are sampled, sampled parents are always followed
+}
+
+// Init installs the W3C trace context propagator and, when an endpoint is configured, a tracer provider exporting
+// spans to it. The returned function flushes and stops the exporter.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = DefaultServiceName
+	}
+	serviceName = cfg.ServiceName
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio <= 0 || cfg.SampleRatio > 1 {
+		cfg.SampleRatio = DefaultSampleRatio
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: creating OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: creating resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the service spans, it is a no-op until Init installs a provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts an internal span named name as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Detach returns a context carrying the span of ctx without its cancellation and deadline, for work that must
+// finish after the request is gone
+func Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}
+
+// End records err on span, when it is not nil, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -2,85 +2,158 @@ package watcher
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"github.com/wankhede04/blockswap.weather/weather-srv/tracing"
+	"github.com/wankhede04/blockswap.weather/weather-srv/webhook"
 	"github.com/wankhede04/blockswap.weather/weather-srv/worker"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
 )
 
+// headInterval is how often the chain head is fetched to export the lag of the watcher
+const headInterval = 15 * time.Second
+
 type WatcherSRV struct {
 	Logs     chan types.Log
 	Sub      ethereum.Subscription
-	Logger   *logrus.Logger
+	Logger   *logrus.Entry // Logger with the chain field
 	DataBase *db.PostgresDataBase
 	Worker   *worker.Worker
+	Webhooks *webhook.Dispatcher
 	ctx      context.Context
 	cancelFn context.CancelFunc
-	dbPool   *db.ConnectionPool // Custom connection pool
-	dbMutex  sync.RWMutex       // Mutex for database connection synchronization
+	cursor   uint64         // Block height the watcher has processed, updated atomically
+	head     uint64         // Chain head fetched by trackHead, updated atomically
+	live     int32          // 1 while the event subscription is live, updated atomically
+	wg       sync.WaitGroup // Running goroutines, waited for by Stop
+}
+
+// Status is a snapshot of the event subscription and progress of the watcher
+type Status struct {
+	Subscribed bool   // Whether the event subscription is live
+	Cursor     uint64 // Block height the watcher has processed
+	Head       uint64 // Chain head at the last fetch, 0 until it is fetched
 }
 
 // NewWatcherSRV creates a new WatcherSRV instance
-func NewWatcherSRV(database *db.PostgresDataBase, logger *logrus.Logger, wrkr *worker.Worker) (*WatcherSRV, error) {
+func NewWatcherSRV(database *db.PostgresDataBase, logger *logrus.Logger, wrkr *worker.Worker, webhooks *webhook.Dispatcher) (*WatcherSRV, error) {
 	logs := make(chan types.Log)
 
-	subs, err := wrkr.SubscribeToLogs(logs)
-	if err != nil {
-		return nil, err
-	}
-
 	ctx, cancelFn := context.WithCancel(context.Background())
 
-	// Create a connection pool with a maximum number of connections
-	dbPool, err := db.NewConnectionPool(database.DB, 10) // Adjust the maximum number of connections as per your requirement
+	subs, err := wrkr.SubscribeToLogs(ctx, logs)
 	if err != nil {
-		cancelFn() // Call cancelFn in case of error
+		cancelFn()
 		return nil, err
 	}
 
+	// The subscription resumes from the last stored event, so does the cursor
+	var cursor uint64
+	if lastEvent, err := database.FindLastEventLog(wrkr.ChainName); err == nil {
+		cursor = lastEvent.BlockHeight
+	}
+
 	return &WatcherSRV{
 		Logs:     logs,
 		Sub:      subs,
-		Logger:   logger,
+		Logger:   logger.WithField("chain", wrkr.ChainName),
 		DataBase: database,
 		Worker:   wrkr,
+		Webhooks: webhooks,
 		ctx:      ctx,
 		cancelFn: cancelFn,
-		dbPool:   dbPool,
-		dbMutex:  sync.RWMutex{},
+		cursor:   cursor,
+		live:     1,
 	}, nil
 }
 
 // Run starts the WatcherSRV and begins processing event logs
 func (w *WatcherSRV) Run() {
-	go w.processEventLogs()
+	metrics.WatcherCursorHeight.WithLabelValues(w.Worker.ChainName).Set(float64(atomic.LoadUint64(&w.cursor)))
+	w.wg.Add(2)
+	go func() {
+		defer w.wg.Done()
+		w.processEventLogs()
+	}()
+	go func() {
+		defer w.wg.Done()
+		w.trackHead()
+	}()
 }
 
-// getDBConnection acquires a database connection from the pool
-func (w *WatcherSRV) getDBConnection() (*gorm.DB, error) {
-	w.dbMutex.Lock()
-	defer w.dbMutex.Unlock()
+// Stop stops processing event logs once the one being handled is stored, then ends the event subscription
+func (w *WatcherSRV) Stop() {
+	w.cancelFn()
+	w.wg.Wait()
+	w.Sub.Unsubscribe()
+}
+
+// Status returns the subscription state, cursor and last fetched head of the watcher
+func (w *WatcherSRV) Status() Status {
+	return Status{
+		Subscribed: atomic.LoadInt32(&w.live) == 1,
+		Cursor:     atomic.LoadUint64(&w.cursor),
+		Head:       atomic.LoadUint64(&w.head),
+	}
+}
 
-	db, err := w.dbPool.AcquireConnection()
+// trackHead periodically fetches the chain head and exports how many blocks the watcher is behind it.
+// A live subscription delivers the logs of a block shortly after it is mined, so the head fetched at the previous
+// tick counts as processed while the subscription stayed live in between.
+func (w *WatcherSRV) trackHead() {
+	ticker := time.NewTicker(headInterval)
+	defer ticker.Stop()
+	var processed uint64
+	for {
+		w.fetchHead(&processed)
+		select {
+		case <-ticker.C:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// fetchHead fetches the chain head, advances the cursor to processed and updates the lag metric
+func (w *WatcherSRV) fetchHead(processed *uint64) {
+	head, err := w.Worker.GetLatestBlock(w.ctx)
 	if err != nil {
-		return nil, err
+		w.Logger.Warnf("Unable to fetch chain head: %v", err)
+		return
+	}
+	if atomic.LoadInt32(&w.live) == 1 {
+		w.advanceCursor(*processed)
+		*processed = head.Uint64()
+	} else {
+		*processed = 0
+	}
+	atomic.StoreUint64(&w.head, head.Uint64())
+
+	lag := float64(head.Uint64()) - float64(atomic.LoadUint64(&w.cursor))
+	if lag < 0 {
+		lag = 0
 	}
+	metrics.WatcherHeadLag.WithLabelValues(w.Worker.ChainName).Set(lag)
+}
 
-	return db, nil
+// getDBConnection acquires a connection from the shared pool, waiting until ctx is done
+func (w *WatcherSRV) getDBConnection(ctx context.Context) (*gorm.DB, error) {
+	return w.DataBase.Pool.AcquireConnection(ctx)
 }
 
 // releaseDBConnection releases a database connection back to the pool
 func (w *WatcherSRV) releaseDBConnection(db *gorm.DB) {
-	w.dbMutex.Lock()
-	defer w.dbMutex.Unlock()
-
-	w.dbPool.ReleaseConnection(db)
+	w.DataBase.Pool.ReleaseConnection(db)
 }
 
 // processEventLogs continuously listens for event logs and handles them
@@ -88,13 +161,18 @@ func (w *WatcherSRV) processEventLogs() {
 	for {
 		select {
 		case err := <-w.Sub.Err():
+			if w.ctx.Err() != nil {
+				return
+			}
+			atomic.StoreInt32(&w.live, 0)
 			w.Logger.Errorf("Error received in event subscription: %v", err)
 			w.renewSubscription()
 		case vLog := <-w.Logs:
 			err := w.handleEventLog(vLog)
 			if err != nil {
-				w.Logger.Errorf("Error processing event log: %v", err)
+				eventLogger(w.Logger, vLog).Errorf("Error processing event log: %v", err)
 			}
+			w.advanceCursor(vLog.BlockNumber)
 		case <-w.ctx.Done():
 			w.Logger.Info("Watcher service has stopped")
 			return
@@ -102,85 +180,153 @@ func (w *WatcherSRV) processEventLogs() {
 	}
 }
 
-// renewSubscription renews the event subscription
+// renewSubscription renews the event subscription, retrying until it succeeds or the watcher is stopped
 func (w *WatcherSRV) renewSubscription() {
 	for {
-		subs, err := w.Worker.SubscribeToLogs(w.Logs)
+		subs, err := w.Worker.SubscribeToLogs(w.ctx, w.Logs)
 		if err != nil {
+			metrics.WatcherSubscriptionRenewals.WithLabelValues(w.Worker.ChainName, "error").Inc()
 			w.Logger.Errorf("Failed to renew event subscription: %v", err)
-			time.Sleep(10 * time.Second)
+			select {
+			case <-time.After(10 * time.Second):
+			case <-w.ctx.Done():
+				return
+			}
 		} else {
+			metrics.WatcherSubscriptionRenewals.WithLabelValues(w.Worker.ChainName, "success").Inc()
 			w.Sub = subs
+			atomic.StoreInt32(&w.live, 1)
 			w.Logger.Info("Event subscription renewed successfully")
 			return
 		}
 	}
 }
 
-// handleEventLog handles an individual event log
-func (w *WatcherSRV) handleEventLog(vLog types.Log) error {
+// advanceCursor moves the cursor to a processed block, replayed events do not move it back
+func (w *WatcherSRV) advanceCursor(height uint64) {
+	if height <= atomic.LoadUint64(&w.cursor) {
+		return
+	}
+	atomic.StoreUint64(&w.cursor, height)
+	metrics.WatcherCursorHeight.WithLabelValues(w.Worker.ChainName).Set(float64(height))
+}
+
+// eventLogger adds the block height and transaction hash of an event log to the entries of logger
+func eventLogger(logger *logrus.Entry, vLog types.Log) *logrus.Entry {
+	return logger.WithFields(logrus.Fields{"block": vLog.BlockNumber, "tx_hash": vLog.TxHash.Hex()})
+}
+
+// handleEventLog handles an individual event log in its own trace and counts it by event type and result
+func (w *WatcherSRV) handleEventLog(vLog types.Log) (err error) {
+	eventName := "unknown"
+	ctx, span := tracing.Start(w.ctx, "watcher.handleEventLog",
+		attribute.String("chain.name", w.Worker.ChainName),
+		attribute.Int64("block.height", int64(vLog.BlockNumber)),
+		attribute.String("tx.hash", vLog.TxHash.Hex()),
+	)
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		metrics.WatcherEvents.WithLabelValues(w.Worker.ChainName, eventName, result).Inc()
+		span.SetAttributes(attribute.String("event.type", eventName))
+		tracing.End(span, err)
+	}()
+
+	logger := eventLogger(w.Logger.WithContext(ctx), vLog)
+
 	var tLog db.EventLog
 
 	tLog.BlockHeight = vLog.BlockNumber
 	tLog.TransactionHash = vLog.TxHash.Hex()
+	tLog.ChainName = w.Worker.ChainName
 
 	event, eventType, err := worker.ParseEvent(&vLog)
 	if err != nil {
 		return err
 	}
-	database, err := w.getDBConnection()
+	eventName = eventType
+	// Queries of the event run with its context, so that they are traced under its span
+	database, err := w.getDBConnection(ctx)
 	if err != nil {
-		w.Logger.Errorf("Error: unable to  create connection pool %v\n", err)
+		return err
 	}
 	defer w.releaseDBConnection(database) // Ensure the connection is released
+	tLog.EventType = eventType
+	origin := db.MembershipOrigin{ChainName: w.Worker.ChainName, RegistrationContract: w.Worker.GetRegistrationContract().Hex()}
+
+	var status db.MembershipStatus
+	var webhookEvent string
 	switch eventType {
 	case "ParticipantRegistered":
-		ParticipantRegistered := event.(worker.RegistrationParticipantRegistered)
-		tLog.Address = ParticipantRegistered.Participant.Hex()
-
-		membership, err := db.FindMemberShip(database, tLog.Address)
-		if err != nil {
-			membership := db.Membership{
-				Address: tLog.Address,
-				Status:  string(db.Registered),
-			}
-			err := db.CreateMembership(database, &membership)
-			if err != nil {
-				w.Logger.Errorf("Error: unable to  create %v\n", err)
-			}
-		} else {
-			if err := db.UpdateMemberShipStatus(database, membership.Address, db.Registered); err != nil {
-				w.Logger.Errorf("Error: unable to update DB %v\n", err)
-			}
-		}
-		w.Logger.Infof("Found ParticipantRegistered event and updated membership status successfully with member %s", tLog.Address)
-
+		tLog.Address = event.(worker.RegistrationParticipantRegistered).Participant.Hex()
+		status, webhookEvent = db.Registered, webhook.EventMemberRegistered
 	case "ParticipantResigned":
-		ParticipantResigned := event.(worker.RegistrationParticipantResigned)
-		tLog.Address = ParticipantResigned.Participant.Hex()
+		tLog.Address = event.(worker.RegistrationParticipantResigned).Participant.Hex()
+		status, webhookEvent = db.Resigned, webhook.EventMemberResigned
+	}
 
-		membership, err := db.FindMemberShip(database, tLog.Address)
+	// The status change, its event log and its webhook deliveries are committed together
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if status == "" {
+			return db.CreateEventLog(tx, &tLog)
+		}
+		membership, err := db.FindMemberShip(tx, tLog.Address)
 		if err != nil {
 			membership := db.Membership{
-				Address: tLog.Address,
-				Status:  string(db.Resigned),
+				Address:              tLog.Address,
+				Status:               string(status),
+				ChainName:            origin.ChainName,
+				RegistrationContract: origin.RegistrationContract,
 			}
-			err := db.CreateMembership(database, &membership)
-			if err != nil {
-				w.Logger.Errorf("Error: unable to  create %v\n", err)
+			if err := db.CreateMembership(tx, &membership); err != nil {
+				return fmt.Errorf("unable to create membership: %w", err)
 			}
 		} else {
-			if err := db.UpdateMemberShipStatus(database, membership.Address, db.Resigned); err != nil {
-				w.Logger.Errorf("Error: unable to update DB %v\n", err)
+			if err := db.UpdateMemberShipStatus(tx, membership.Address, status); err != nil {
+				return fmt.Errorf("unable to update membership status: %w", err)
+			}
+			if err := db.UpdateMembershipOrigin(tx, membership.Address, origin); err != nil {
+				return fmt.Errorf("unable to update membership origin: %w", err)
 			}
 		}
-
-		w.Logger.Infof("Found ParticipantResigned event and updated membership status successfully with member %s", tLog.Address)
+		if err := w.notifyMembership(tx, webhookEvent, status, tLog); err != nil {
+			return err
+		}
+		if err := db.CreateEventLog(tx, &tLog); err != nil {
+			return fmt.Errorf("unable to create event log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-
-	if err := db.CreateEventLog(database, &tLog); err != nil {
-		w.Logger.Errorf("Error creating event log: %v", err)
+	if status != "" {
+		logger.Infof("Found %s event and updated membership status successfully with member %s", eventType, tLog.Address)
 	}
-
 	return nil
 }
+
+// MembershipEvent is the webhook data of a membership status change
+type MembershipEvent struct {
+	Address         string `json:"address"`
+	Status          string `json:"status"`
+	ChainName       string `json:"chain_name"`
+	BlockHeight     uint64 `json:"block_height"`
+	TransactionHash string `json:"transaction_hash"`
+}
+
+// notifyMembership stores the webhook deliveries of a membership status change in the transaction of the change
+func (w *WatcherSRV) notifyMembership(tx *gorm.DB, eventType string, status db.MembershipStatus, tLog db.EventLog) error {
+	if w.Webhooks == nil {
+		return nil
+	}
+	return w.Webhooks.Enqueue(tx, eventType, MembershipEvent{
+		Address:         tLog.Address,
+		Status:          string(status),
+		ChainName:       w.Worker.ChainName,
+		BlockHeight:     tLog.BlockHeight,
+		TransactionHash: tLog.TransactionHash,
+	})
+}
@@ -0,0 +1,194 @@
+package weatherservice
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"gorm.io/gorm"
+)
+
+// SuspensionRequest is the payload suspending a member off-chain
+type SuspensionRequest struct {
+	Reason    string `json:"reason" binding:"required"`
+	ExpiresAt int64  `json:"expires_at"` // Unix time the suspension expires, 0 or omitted until it is lifted
+}
+
+// MemberOverridesRequest is the payload overriding member settings, omitted fields are left unchanged
+type MemberOverridesRequest struct {
+	Region     *string `json:"region"`
+	RatePeriod *int64  `json:"rate_period"` // Seconds between reporting windows, 0 uses the service default
+	RateWindow *int64  `json:"rate_window"` // Seconds a reporting window stays open, 0 uses the service default
+}
+
+// AuditLogEntryResponse is an admin action recorded in the audit log
+type AuditLogEntryResponse struct {
+	ID         uint            `json:"id"`
+	Action     string          `json:"action"`
+	Address    string          `json:"address"`
+	Details    json.RawMessage `json:"details"`
+	RequestID  string          `json:"request_id"`
+	RemoteAddr string          `json:"remote_addr"`
+	CreatedAt  int64           `json:"created_at"`
+}
+
+// SuspendMemberHandler suspends a member until the suspension expires or is lifted, whatever its chain status.
+// Suspended members are rejected by the report authentication.
+func (s *WeatherService) SuspendMemberHandler(c *gin.Context) {
+	address, ok := memberAddressParam(c)
+	if !ok {
+		return
+	}
+	var payload SuspensionRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+	if payload.ExpiresAt < 0 || (payload.ExpiresAt > 0 && payload.ExpiresAt <= time.Now().Unix()) {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Suspension must expire in the future", nil)
+		return
+	}
+
+	err := s.auditedMemberUpdate(c, db.AuditMemberSuspended, address, payload, func(tx *gorm.DB) error {
+		return db.UpdateMembershipSuspension(tx, address, true, payload.Reason, payload.ExpiresAt)
+	})
+	s.writeMemberUpdate(c, address, err)
+}
+
+// LiftSuspensionHandler lifts the suspension of a member
+func (s *WeatherService) LiftSuspensionHandler(c *gin.Context) {
+	address, ok := memberAddressParam(c)
+	if !ok {
+		return
+	}
+
+	err := s.auditedMemberUpdate(c, db.AuditMemberSuspensionLifted, address, gin.H{}, func(tx *gorm.DB) error {
+		return db.UpdateMembershipSuspension(tx, address, false, "", 0)
+	})
+	s.writeMemberUpdate(c, address, err)
+}
+
+// UpdateMemberOverridesHandler overrides the region and rate limit settings of a member
+func (s *WeatherService) UpdateMemberOverridesHandler(c *gin.Context) {
+	address, ok := memberAddressParam(c)
+	if !ok {
+		return
+	}
+	var payload MemberOverridesRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+	if (payload.RatePeriod != nil && *payload.RatePeriod < 0) || (payload.RateWindow != nil && *payload.RateWindow < 0) {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Rate settings must not be negative", nil)
+		return
+	}
+
+	overrides := db.MembershipOverrides{Region: payload.Region, RatePeriod: payload.RatePeriod, RateWindow: payload.RateWindow}
+	err := s.auditedMemberUpdate(c, db.AuditMemberOverridesUpdated, address, payload, func(tx *gorm.DB) error {
+		return db.UpdateMembershipOverrides(tx, address, overrides)
+	})
+	s.writeMemberUpdate(c, address, err)
+}
+
+// AuditLogHandler lists the admin actions, newest first, optionally filtered by the address query param
+func (s *WeatherService) AuditLogHandler(c *gin.Context) {
+	address := c.Query("address")
+	if address != "" {
+		if !common.IsHexAddress(address) {
+			apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid address", nil)
+			return
+		}
+		address = common.HexToAddress(address).Hex()
+	}
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	entries, err := db.FindAdminAuditLogs(database, address, limit, offset)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	response := make([]AuditLogEntryResponse, len(entries))
+	for i, e := range entries {
+		response[i] = AuditLogEntryResponse{
+			ID:         e.ID,
+			Action:     e.Action,
+			Address:    e.Address,
+			Details:    json.RawMessage(e.Details),
+			RequestID:  e.RequestID,
+			RemoteAddr: e.RemoteAddr,
+			CreatedAt:  e.CreatedAt.Unix(),
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": response})
+}
+
+// memberAddressParam returns the checksummed address path param, memberships are stored checksummed
+func memberAddressParam(c *gin.Context) (string, bool) {
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid address", nil)
+		return "", false
+	}
+	return common.HexToAddress(address).Hex(), true
+}
+
+// auditedMemberUpdate applies update and records it in the audit log within one transaction
+func (s *WeatherService) auditedMemberUpdate(c *gin.Context, action, address string, details interface{}, update func(tx *gorm.DB) error) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		return err
+	}
+	defer s.releaseDBConnection(database)
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		if err := update(tx); err != nil {
+			return err
+		}
+		return db.CreateAdminAuditLog(tx, &db.AdminAuditLog{
+			Action:     action,
+			Address:    address,
+			Details:    string(encoded),
+			RequestID:  apierror.RequestID(c),
+			RemoteAddr: c.ClientIP(),
+		})
+	})
+}
+
+// writeMemberUpdate writes the updated member, or the error of the update
+func (s *WeatherService) writeMemberUpdate(c *gin.Context, address string, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Write(c, http.StatusNotFound, CodeMemberNotFound, "Member not found", nil)
+		return
+	}
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	member, err := s.LookupMember(c.Request.Context(), address)
+	if err != nil {
+		s.writeReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
@@ -0,0 +1,34 @@
+package weatherservice
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+)
+
+// AdminKeyHeader carries the admin API key
+const AdminKeyHeader = "X-Admin-Key"
+
+// codeAdminKey is the auth failure reason of requests with a wrong admin key, they get the unauthorized code
+const codeAdminKey = "admin_key"
+
+// AdminMiddleware restricts admin endpoints to requests carrying the configured admin API key
+func (s *WeatherService) AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.adminKey == "" {
+			apierror.Abort(c, http.StatusNotFound, apierror.CodeDisabled, "Admin API disabled", nil)
+			return
+		}
+
+		key := c.GetHeader(AdminKeyHeader)
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.adminKey)) != 1 {
+			metrics.AuthFailures.WithLabelValues(codeAdminKey).Inc()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized", nil)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,208 @@
+package weatherservice
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/webhook"
+	"gorm.io/gorm"
+)
+
+// maxListLimit is the maximum page size of admin listings
+const maxListLimit = 100
+
+// WebhookSubscriptionRequest is the payload creating a webhook subscription
+type WebhookSubscriptionRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	Secret     string   `json:"secret" binding:"required"`
+	EventTypes []string `json:"event_types"`
+}
+
+// WebhookSubscriptionResponse is a webhook subscription without its secret
+type WebhookSubscriptionResponse struct {
+	ID         uint     `json:"id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     bool     `json:"active"`
+	CreatedAt  int64    `json:"created_at"`
+}
+
+// WebhookDeliveryResponse is a delivery attempt history entry
+type WebhookDeliveryResponse struct {
+	ID            uint   `json:"id"`
+	EventType     string `json:"event_type"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	ResponseCode  int    `json:"response_code"`
+	LastError     string `json:"last_error,omitempty"`
+	NextAttemptAt int64  `json:"next_attempt_at,omitempty"`
+	DeliveredAt   int64  `json:"delivered_at,omitempty"`
+	CreatedAt     int64  `json:"created_at"`
+	Payload       string `json:"payload"`
+}
+
+func toWebhookSubscriptionResponse(sub db.WebhookSubscription) WebhookSubscriptionResponse {
+	eventTypes := []string{}
+	if sub.EventTypes != "" {
+		eventTypes = strings.Split(sub.EventTypes, ",")
+	}
+	return WebhookSubscriptionResponse{
+		ID:         sub.ID,
+		URL:        sub.URL,
+		EventTypes: eventTypes,
+		Active:     sub.Active,
+		CreatedAt:  sub.CreatedAt.Unix(),
+	}
+}
+
+// CreateWebhookHandler creates a webhook subscription
+func (s *WeatherService) CreateWebhookHandler(c *gin.Context) {
+	var payload WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+	if u, err := url.Parse(payload.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid webhook url", nil)
+		return
+	}
+	for _, t := range payload.EventTypes {
+		if !webhook.ValidEventType(t) {
+			apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Unknown event type "+t, gin.H{"event_type": t})
+			return
+		}
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	sub := db.WebhookSubscription{
+		URL:        payload.URL,
+		Secret:     payload.Secret,
+		EventTypes: strings.Join(payload.EventTypes, ","),
+		Active:     true,
+	}
+	if err := db.CreateWebhookSubscription(database, &sub); err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toWebhookSubscriptionResponse(sub))
+}
+
+// ListWebhooksHandler lists the webhook subscriptions
+func (s *WeatherService) ListWebhooksHandler(c *gin.Context) {
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	subscriptions, err := db.FindWebhookSubscriptions(database)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	response := make([]WebhookSubscriptionResponse, len(subscriptions))
+	for i, sub := range subscriptions {
+		response[i] = toWebhookSubscriptionResponse(sub)
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": response})
+}
+
+// DeleteWebhookHandler deletes a webhook subscription, its pending deliveries are marked failed
+func (s *WeatherService) DeleteWebhookHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid webhook id", nil)
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	if err := db.DeleteWebhookSubscription(database, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(c, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found", nil)
+			return
+		}
+		s.internalServerError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveriesHandler returns the delivery history of a webhook subscription, newest first
+func (s *WeatherService) ListWebhookDeliveriesHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid webhook id", nil)
+		return
+	}
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	deliveries, err := db.FindWebhookDeliveries(database, uint(id), limit, offset)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	response := make([]WebhookDeliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		response[i] = WebhookDeliveryResponse{
+			ID:           d.ID,
+			EventType:    d.EventType,
+			Status:       d.Status,
+			Attempts:     d.Attempts,
+			ResponseCode: d.ResponseCode,
+			LastError:    d.LastError,
+			CreatedAt:    d.CreatedAt.Unix(),
+			Payload:      d.Payload,
+		}
+		if d.Status == string(db.DeliveryPending) {
+			response[i].NextAttemptAt = d.NextAttemptAt.Unix()
+		}
+		if d.DeliveredAt != nil {
+			response[i].DeliveredAt = d.DeliveredAt.Unix()
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": response, "limit": limit, "offset": offset})
+}
+
+// pagination reads the limit and offset query params, it writes a 400 and returns false when they are invalid
+func pagination(c *gin.Context) (int, int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxListLimit {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid limit", nil)
+		return 0, 0, false
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid offset", nil)
+		return 0, 0, false
+	}
+	return limit, offset, true
+}
@@ -0,0 +1,275 @@
+package weatherservice
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/auth"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"gorm.io/gorm"
+)
+
+// SignInRequest is the payload exchanging a signed Sign-In with Ethereum message for session tokens
+type SignInRequest struct {
+	Message   string `json:"message"`   // EIP-4361 message
+	Signature string `json:"signature"` // personal_sign signature of the message by its address
+}
+
+// RefreshRequest is the payload refreshing or revoking a session
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionTokens are the tokens of a session, the refresh token is rotated on every refresh
+type SessionTokens struct {
+	Address          string `json:"address"`
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+// sessionsEnabled aborts with feature_disabled when no session domain or token secret is configured
+func (s *WeatherService) sessionsEnabled(c *gin.Context) bool {
+	if s.sessions == nil {
+		apierror.Abort(c, http.StatusNotFound, apierror.CodeDisabled, "Sessions disabled", nil)
+		return false
+	}
+	return true
+}
+
+// AuthNonceHandler hands out a single use nonce to include in a Sign-In with Ethereum message
+func (s *WeatherService) AuthNonceHandler(c *gin.Context) {
+	if !s.sessionsEnabled(c) {
+		return
+	}
+	cfg := s.sessions.Config()
+
+	nonce, err := auth.NewNonce()
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	now := time.Now()
+	record := db.AuthNonce{Nonce: nonce, ExpiresAt: now.Add(cfg.NonceTTL).Unix()}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	if err := db.CreateAuthNonce(database, &record, now.Unix()); err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"nonce":      nonce,
+		"expires_at": record.ExpiresAt,
+		"domain":     cfg.Domain,
+		"chain_id":   s.worker.GetChainID(),
+	})
+}
+
+// AuthVerifyHandler verifies a signed Sign-In with Ethereum message and opens a session for its address
+func (s *WeatherService) AuthVerifyHandler(c *gin.Context) {
+	if !s.sessionsEnabled(c) {
+		return
+	}
+	var payload SignInRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+
+	now := time.Now()
+	message, err := auth.ParseMessage(payload.Message)
+	if err == nil {
+		err = s.checkSignInMessage(message, now)
+	}
+	if err != nil {
+		metrics.AuthFailures.WithLabelValues(CodeSignInMessage).Inc()
+		apierror.Write(c, http.StatusBadRequest, CodeSignInMessage, "Invalid sign-in message", gin.H{"reason": err.Error()})
+		return
+	}
+
+	// Sign-in messages are signed with personal_sign, recovered like every other EOA signature
+	if err := VerifySigner(accounts.TextHash([]byte(payload.Message)), payload.Signature, message.Address); err != nil {
+		if IsInvalidSignature(err) {
+			s.RecordInvalidSignature(c.Request.Context(), net.ParseIP(c.ClientIP()))
+		}
+		metrics.AuthFailures.WithLabelValues(signatureErrorCode(err)).Inc()
+		apierror.Write(c, http.StatusUnauthorized, signatureErrorCode(err), "Error in verification", nil)
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	consumed, err := db.ConsumeAuthNonce(database, message.Nonce, now.Unix())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	if !consumed {
+		metrics.AuthFailures.WithLabelValues(CodeNonceInvalid).Inc()
+		apierror.Write(c, http.StatusUnauthorized, CodeNonceInvalid, "Nonce is unknown, expired or already used", nil)
+		return
+	}
+
+	if !s.checkSessionMember(c, database, message.Address) {
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	session := db.AuthSession{
+		Address:          message.Address,
+		RefreshTokenHash: refreshHash,
+		ExpiresAt:        now.Add(s.sessions.Config().RefreshTTL).Unix(),
+	}
+	if err := db.CreateAuthSession(database, &session); err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	s.writeSessionTokens(c, session, refreshToken, now)
+}
+
+// AuthRefreshHandler rotates the refresh token of a session and issues a new access token
+func (s *WeatherService) AuthRefreshHandler(c *gin.Context) {
+	if !s.sessionsEnabled(c) {
+		return
+	}
+	var payload RefreshRequest
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.RefreshToken == "" {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	now := time.Now()
+	tokenHash := auth.HashRefreshToken(payload.RefreshToken)
+	// Members that resigned or were suspended since they signed in cannot refresh their session
+	session, err := db.FindLiveAuthSession(database, tokenHash, now.Unix())
+	if err == nil {
+		if !s.checkSessionMember(c, database, session.Address) {
+			return
+		}
+		session, err = db.RotateAuthSession(database, tokenHash, refreshHash, now.Unix())
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			metrics.AuthFailures.WithLabelValues(CodeRefreshTokenInvalid).Inc()
+			apierror.Write(c, http.StatusUnauthorized, CodeRefreshTokenInvalid, "Refresh token is unknown, expired or revoked", nil)
+			return
+		}
+		s.internalServerError(c, err)
+		return
+	}
+	s.writeSessionTokens(c, *session, refreshToken, now)
+}
+
+// checkSessionMember writes the error of an address that may not hold a session: it has no membership, the
+// membership is not registered or it is suspended. It returns false when the error was written.
+func (s *WeatherService) checkSessionMember(c *gin.Context, database *gorm.DB, address string) bool {
+	_, err := findRegisteredMember(database, address)
+	var suspended *ReportError
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		metrics.AuthFailures.WithLabelValues(CodeMemberNotFound).Inc()
+		apierror.Write(c, http.StatusUnauthorized, CodeMemberNotFound, "Member not found", nil)
+	case errors.Is(err, errMemberNotRegistered):
+		metrics.AuthFailures.WithLabelValues(CodeMemberNotRegistered).Inc()
+		apierror.Write(c, http.StatusUnauthorized, CodeMemberNotRegistered, "Member is not registered", nil)
+	case errors.As(err, &suspended):
+		metrics.AuthFailures.WithLabelValues(suspended.Code).Inc()
+		s.writeReportError(c, suspended)
+	default:
+		s.internalServerError(c, err)
+	}
+	return false
+}
+
+// AuthLogoutHandler revokes the session of a refresh token, access tokens already issued stay valid until they expire
+func (s *WeatherService) AuthLogoutHandler(c *gin.Context) {
+	if !s.sessionsEnabled(c) {
+		return
+	}
+	var payload RefreshRequest
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.RefreshToken == "" {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	if err := db.RevokeAuthSession(database, auth.HashRefreshToken(payload.RefreshToken)); err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// checkSignInMessage checks that the message was made for this service, chain and time
+func (s *WeatherService) checkSignInMessage(message *auth.Message, now time.Time) error {
+	if message.Domain != s.sessions.Config().Domain {
+		return fmt.Errorf("domain %s does not match", message.Domain)
+	}
+	if message.ChainID != s.worker.GetChainID() {
+		return fmt.Errorf("chain id %d does not match", message.ChainID)
+	}
+	return message.ValidAt(now)
+}
+
+// writeSessionTokens issues an access token for the session and writes it with the refresh token
+func (s *WeatherService) writeSessionTokens(c *gin.Context, session db.AuthSession, refreshToken string, now time.Time) {
+	cfg := s.sessions.Config()
+	accessToken, err := s.sessions.IssueAccessToken(session.Address, session.ID, now)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, SessionTokens{
+		Address:          session.Address,
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(cfg.AccessTTL.Seconds()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresIn: session.ExpiresAt - now.Unix(),
+	})
+}
@@ -1,22 +1,27 @@
 package weatherservice
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
 	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"gorm.io/gorm"
 )
 
 type WeatherReport struct {
-	Address   string `json:"address"`
-	Report    string `json:"report"`
-	Signature string `json:"signature"`
+	Address         string `json:"address"`
+	Report          string `json:"report"`
+	Signature       string `json:"signature"`
+	SignatureScheme string `json:"signature_scheme"` // eip712, eip191 or eip1271
 }
 
 // AuthenticateMiddleware checks if user is registered on contract and verify data provided by user
@@ -24,100 +29,101 @@ func (s *WeatherService) AuthenticateMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		payload := WeatherReport{}
 		if err := c.ShouldBindJSON(&payload); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-			c.Abort()
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
 			return
 		}
 
-		address := payload.Address
-
-		if err := VerifyOrderSignature(payload, s.worker.GetChainID(), s.worker.GetRegistrationContract().String()); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error in verification"})
-			c.Abort()
-			return
-		}
-
-		// Acquire a database connection
-		database, err := s.getDBConnection()
+		membership, typedDataHash, err := s.AuthenticateReport(c.Request.Context(), payload)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			c.Abort()
-			return
-		}
-		// Release the database connection
-		defer s.releaseDBConnection(database)
-		var membership db.Membership
-		if err := database.Where("address = ?", address).First(&membership).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
-			return
-		}
-
-		if membership.Status != string(db.Registered) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			if IsInvalidSignature(err) {
+				s.RecordInvalidSignature(c.Request.Context(), net.ParseIP(c.ClientIP()))
+			}
+			s.writeReportError(c, err)
 			c.Abort()
 			return
 		}
 
-		// s.releaseDBConnection(database)
-
 		c.Set("membership", membership)
 		c.Set("report", payload.Report)
+		c.Set("typed_data_hash", typedDataHash)
 
 		c.Next()
 	}
 }
 
-func VerifyOrderSignature(weatherReport WeatherReport, chainID int64, peripheryContract string) error {
-	hash, err := EncodeOrderStruct(weatherReport, chainID, peripheryContract)
+// typedDataHash returns the hex EIP-712 hash of the report for the current chain and registration contract
+func (s *WeatherService) typedDataHash(report WeatherReport) (string, error) {
+	hash, err := EncodeOrderStruct(report, s.worker.GetChainID(), s.worker.GetRegistrationContract().String())
 	if err != nil {
-		return err
+		return "", err
 	}
+	return hexutil.Encode(hash), nil
+}
 
-	sign, err := hexutil.Decode(weatherReport.Signature)
-	if err != nil {
-		return err
+// errMemberNotRegistered is returned by findRegisteredMember for a membership that is not registered, e.g. resigned
+var errMemberNotRegistered = errors.New("member is not registered")
+
+// findRegisteredMember loads the membership for address and checks that it is registered.
+// A registered member with an active suspension gets a ReportError with status 403.
+func findRegisteredMember(database *gorm.DB, address string) (db.Membership, error) {
+	var membership db.Membership
+	if err := database.Where("address = ?", address).First(&membership).Error; err != nil {
+		return membership, err
 	}
 
-	if sign[64] >= 27 {
-		sign[64] -= 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	if membership.Status != string(db.Registered) {
+		return membership, fmt.Errorf("%w: membership %s is %s", errMemberNotRegistered, address, membership.Status)
+	}
+	if membership.IsSuspended(time.Now().Unix()) {
+		return membership, &ReportError{
+			Status:  http.StatusForbidden,
+			Code:    CodeMemberSuspended,
+			Message: "Member suspended",
+			Details: toSuspensionResponse(membership),
+		}
 	}
+	return membership, nil
+}
 
-	sigPubKeyBytes, err := crypto.Ecrecover(hash, sign)
+// VerifyOrderSignature verifies that the EIP-712 signature of the report was made by its address
+func VerifyOrderSignature(weatherReport WeatherReport, chainID int64, peripheryContract string) error {
+	hash, err := EncodeOrderStruct(weatherReport, chainID, peripheryContract)
 	if err != nil {
-		return err
+		return &SignatureError{Code: CodeTypedData, Err: err}
 	}
 
-	var buf []byte
-
-	hash0 := crypto.Keccak256Hash(sigPubKeyBytes[1:])
-	buf = hash0.Bytes()
-	publicAddress := hexutil.Encode(buf[12:])
-
-	hex := strings.ToLower(publicAddress)[2:]
-	checkSummedAddress := "0x"
-	hashCs := crypto.Keccak256Hash([]byte(hex))
+	return VerifySigner(hash, weatherReport.Signature, weatherReport.Address)
+}
 
-	for i, b := range hex {
-		c := string(b)
-		if b < '0' || b > '9' {
-			if hashCs[i/2]&byte(128-i%2*120) != 0 {
-				c = string(b - 32)
-			}
-		}
-		checkSummedAddress += c
+// VerifySigner verifies that signature over hash was made by address, it is shared by every EOA signature check
+func VerifySigner(hash []byte, signature, address string) error {
+	signer, err := RecoverSigner(hash, signature)
+	if err != nil {
+		return err
 	}
 
-	result := strings.EqualFold(checkSummedAddress, weatherReport.Address)
-	if !result {
-		return fmt.Errorf("signer != trader")
+	if !strings.EqualFold(signer.Hex(), address) {
+		return &SignatureError{Code: CodeSignerMismatch, Err: fmt.Errorf("signer != trader")}
 	}
 	return nil
 }
 
 // EncodeOrderStruct encodes order struct in bytes
 func EncodeOrderStruct(report WeatherReport, chainID int64, positioningContract string) ([]byte, error) {
-	typeddata := apitypes.TypedData{
+	typeddata := ReportTypedData(report, chainID, positioningContract)
+
+	rawData, _, err := apitypes.TypedDataAndHash(typeddata)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rawData, nil
+}
+
+// ReportTypedData builds the EIP-712 typed data of a weather report, it is the single source for signing and verification
+func ReportTypedData(report WeatherReport, chainID int64, verifyingContract string) apitypes.TypedData {
+	return apitypes.TypedData{
 		Types: apitypes.Types{
 			"EIP712Domain": []apitypes.Type{
 				{Name: "name", Type: "string"},
@@ -135,19 +141,11 @@ func EncodeOrderStruct(report WeatherReport, chainID int64, positioningContract
 			Name:              "WeatherReport",
 			Version:           "1",
 			ChainId:           math.NewHexOrDecimal256(chainID),
-			VerifyingContract: positioningContract,
+			VerifyingContract: verifyingContract,
 		},
 		Message: apitypes.TypedDataMessage{
 			"address": report.Address,
 			"report":  report.Report,
 		},
 	}
-
-	rawData, _, err := apitypes.TypedDataAndHash(typeddata)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return rawData, nil
 }
@@ -0,0 +1,56 @@
+package weatherservice
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/concurrency"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+)
+
+// reportLimiterName labels the metrics of the report concurrency limiter
+const reportLimiterName = "reports"
+
+// ReportConcurrencyMiddleware holds a slot of the report concurrency limiter while the request is processed,
+// requests that get no slot in time are rejected with 503 before their body is parsed
+func (s *WeatherService) ReportConcurrencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, err := s.AcquireReportSlot(c.Request.Context())
+		if err != nil {
+			s.writeReportError(c, err)
+			c.Abort()
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+// AcquireReportSlot takes a slot of the report concurrency limiter, queueing up to the queue timeout. It returns a
+// ReportError with status 503 and a Retry-After when the queue is full or no slot was freed in time.
+func (s *WeatherService) AcquireReportSlot(ctx context.Context) (func(), error) {
+	release, err := s.reportLimiter.Acquire(ctx)
+	if err == nil {
+		return release, nil
+	}
+
+	reason := "queue_timeout"
+	if errors.Is(err, concurrency.ErrQueueFull) {
+		reason = "queue_full"
+	} else if !errors.Is(err, concurrency.ErrQueueTimeout) {
+		// The client went away while queued
+		reason = "cancelled"
+	}
+	metrics.LoadShed.WithLabelValues(reportLimiterName, reason).Inc()
+	return nil, &ReportError{
+		Status:     http.StatusServiceUnavailable,
+		Code:       apierror.CodeUnavailable,
+		Message:    "Service at capacity",
+		RetryAfter: int64(math.Ceil(s.reportLimiter.Config().RetryAfter.Seconds())),
+		Err:        err,
+	}
+}
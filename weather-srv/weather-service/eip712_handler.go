@@ -0,0 +1,42 @@
+package weatherservice
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/gin-gonic/gin"
+)
+
+// TypedDataDomain is the EIP-712 domain as expected by eth_signTypedData_v4, with a numeric chainId
+type TypedDataDomain struct {
+	Name              string `json:"name"`
+	Version           string `json:"version"`
+	ChainID           int64  `json:"chainId"`
+	VerifyingContract string `json:"verifyingContract"`
+}
+
+// TypedDataTemplate is the typed data a member fills with address and report before signing
+type TypedDataTemplate struct {
+	Types       apitypes.Types            `json:"types"`
+	PrimaryType string                    `json:"primaryType"`
+	Domain      TypedDataDomain           `json:"domain"`
+	Message     apitypes.TypedDataMessage `json:"message"`
+}
+
+// EIP712Handler returns the typed data template for the current chain and registration contract
+func (s *WeatherService) EIP712Handler(c *gin.Context) {
+	chainID := s.worker.GetChainID()
+	typedData := ReportTypedData(WeatherReport{}, chainID, s.worker.GetRegistrationContract().String())
+
+	c.JSON(http.StatusOK, TypedDataTemplate{
+		Types:       typedData.Types,
+		PrimaryType: typedData.PrimaryType,
+		Domain: TypedDataDomain{
+			Name:              typedData.Domain.Name,
+			Version:           typedData.Domain.Version,
+			ChainID:           chainID,
+			VerifyingContract: typedData.Domain.VerifyingContract,
+		},
+		Message: typedData.Message,
+	})
+}
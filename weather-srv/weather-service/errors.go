@@ -0,0 +1,70 @@
+package weatherservice
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+)
+
+// Error codes of the weather service routes, signature failures use the signature error codes
+const (
+	CodeMemberNotFound      = "member_not_found"      // No membership is stored for the address
+	CodeMemberSuspended     = "member_suspended"      // Member has an active off-chain suspension
+	CodeMemberNotRegistered = "member_not_registered" // Membership is not registered, e.g. the member resigned
+	CodeReportNotFound      = "report_not_found"      // No report has the requested id
+	CodeWebhookNotFound     = "webhook_not_found"     // No webhook subscription has the requested id
+	CodeEpochNotCommitted   = "epoch_not_committed"   // Merkle root of the epoch is not stored yet
+	CodeReportNotCommitted  = "report_not_committed"  // Report was stored after the Merkle root of its epoch
+	CodeBatchSize           = "batch_size"            // Batch is empty or larger than MaxBatchSize
+	CodeClientBanned        = "client_banned"         // Client IP is banned after repeated invalid signatures
+	CodePayloadTooLarge     = "payload_too_large"     // Request body is larger than the configured limit
+
+	CodeSignInMessage       = "siwe_message"          // Sign-in message is malformed or not made for this service
+	CodeNonceInvalid        = "nonce_invalid"         // Sign-in nonce is unknown, expired or already used
+	CodeTokenInvalid        = "token_invalid"         // Access token is malformed or not signed by the service
+	CodeTokenExpired        = "token_expired"         // Access token has expired, refresh the session
+	CodeRefreshTokenInvalid = "refresh_token_invalid" // Refresh token is unknown, expired or revoked
+
+	CodeIdempotencyKeyReused     = "idempotency_key_reused"      // Idempotency key was sent with a different body
+	CodeIdempotencyKeyInProgress = "idempotency_key_in_progress" // First request with the idempotency key has not finished
+)
+
+// writeReportError writes the error envelope of a ReportError, any other error is written as an internal error
+func (s *WeatherService) writeReportError(c *gin.Context, err error) {
+	var reportErr *ReportError
+	if !errors.As(err, &reportErr) || reportErr.Status == http.StatusInternalServerError {
+		s.internalServerError(c, err)
+		return
+	}
+	if reportErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.FormatInt(reportErr.RetryAfter, 10))
+	}
+	apierror.Write(c, reportErr.Status, reportErr.Code, reportErr.Message, reportErr.Details)
+}
+
+// databaseBusyError is the 503 of a request that got no database connection before its deadline
+func databaseBusyError(err error) *ReportError {
+	return &ReportError{Status: http.StatusServiceUnavailable, Code: apierror.CodeUnavailable, Message: "Database busy", RetryAfter: 1, Err: err}
+}
+
+// signatureCheckUnavailableError is the 503 of a signature that could not be checked, e.g. the RPC provider of an
+// EIP-1271 check is unreachable
+func signatureCheckUnavailableError(err error) *ReportError {
+	return &ReportError{Status: http.StatusServiceUnavailable, Code: apierror.CodeUnavailable, Message: "Signature check unavailable", RetryAfter: 1, Err: err}
+}
+
+// internalServerError logs err with the request context and writes an internal error without its cause. A request
+// that got no database connection in time is answered with a 503 instead.
+func (s *WeatherService) internalServerError(c *gin.Context, err error) {
+	if errors.Is(err, db.ErrAcquireTimeout) {
+		s.logger.WithContext(c.Request.Context()).Warnf("Request %s %s failed: %v", c.Request.Method, c.FullPath(), err)
+		s.writeReportError(c, databaseBusyError(err))
+		return
+	}
+	s.logger.WithContext(c.Request.Context()).Errorf("Request %s %s failed: %v", c.Request.Method, c.FullPath(), err)
+	apierror.Write(c, http.StatusInternalServerError, apierror.CodeInternal, apierror.MessageInternal, nil)
+}
@@ -0,0 +1,116 @@
+package weatherservice
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/health"
+)
+
+// registerHealthChecks registers the readiness checks of the components, the service cannot serve reports
+// without the critical ones
+func (s *WeatherService) registerHealthChecks(maxHeadLag uint64) {
+	s.health.Register(health.Check{Name: "database", Critical: true, Run: s.checkDatabase})
+	s.health.Register(health.Check{Name: "chain:" + s.worker.ChainName, Critical: true, Run: s.checkChain})
+	s.health.Register(health.Check{Name: "watcher", Critical: true, Run: func(context.Context) health.Result {
+		return s.checkWatcher(maxHeadLag)
+	}})
+	// Callers wait for a connection of a saturated pool, it slows the service down without making it unavailable
+	s.health.Register(health.Check{Name: "db_pool", Run: func(context.Context) health.Result {
+		return checkPool(s.Database.Pool.Stats())
+	}})
+}
+
+// checkDatabase pings the database
+func (s *WeatherService) checkDatabase(ctx context.Context) health.Result {
+	sqlDB, err := s.Database.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		// The probe is unauthenticated, the cause is only logged
+		s.logger.WithContext(ctx).Warnf("Readiness check database failed: %v", err)
+		return health.Result{Status: health.Down, Error: "database unreachable"}
+	}
+	stats := sqlDB.Stats()
+	return health.Result{Status: health.Up, Details: map[string]interface{}{
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+		"idle":             stats.Idle,
+	}}
+}
+
+// checkChain checks that the RPC provider is reachable and still serves the chain read at startup
+func (s *WeatherService) checkChain(ctx context.Context) health.Result {
+	details := map[string]interface{}{"chain_id": s.worker.GetChainID()}
+	chainID, err := s.worker.FetchChainID(ctx)
+	if err != nil {
+		// ethclient errors embed the provider URL and its API key, the cause is only logged
+		s.logger.WithContext(ctx).Warnf("Readiness check chain %s failed: %v", s.worker.ChainName, err)
+		return health.Result{Status: health.Down, Details: details, Error: "RPC provider unreachable"}
+	}
+	details["rpc_chain_id"] = chainID
+	if chainID != s.worker.GetChainID() {
+		return health.Result{Status: health.Down, Details: details, Error: "chain id mismatch"}
+	}
+	return health.Result{Status: health.Up, Details: details}
+}
+
+// checkWatcher checks that the event subscription is live and the watcher keeps up with the chain head
+func (s *WeatherService) checkWatcher(maxHeadLag uint64) health.Result {
+	status := s.watcher.Status()
+	details := map[string]interface{}{
+		"subscribed":   status.Subscribed,
+		"cursor":       status.Cursor,
+		"head":         status.Head,
+		"max_head_lag": maxHeadLag,
+	}
+	if !status.Subscribed {
+		return health.Result{Status: health.Down, Details: details, Error: "event subscription is being renewed"}
+	}
+	if status.Head == 0 {
+		return health.Result{Status: health.Degraded, Details: details, Error: "chain head not fetched yet"}
+	}
+	var lag uint64
+	if status.Head > status.Cursor {
+		lag = status.Head - status.Cursor
+	}
+	details["head_lag"] = lag
+	if lag > maxHeadLag {
+		return health.Result{Status: health.Degraded, Details: details, Error: "watcher is behind the chain head"}
+	}
+	return health.Result{Status: health.Up, Details: details}
+}
+
+// checkPool reports a pool whose every connection is in use while callers wait as degraded
+func checkPool(stats db.PoolStats) health.Result {
+	details := map[string]interface{}{
+		"max_open": stats.MaxOpen,
+		"open":     stats.Open,
+		"in_use":   stats.InUse,
+		"idle":     stats.Idle,
+		"waiting":  stats.Waiting,
+		"timeouts": stats.Timeouts,
+	}
+	if stats.Waiting > 0 && stats.InUse >= stats.MaxOpen {
+		return health.Result{Status: health.Degraded, Details: details, Error: "callers are waiting for a connection"}
+	}
+	return health.Result{Status: health.Up, Details: details}
+}
+
+// HealthzHandler answers the liveness probe, the process is alive as long as it serves requests
+func (s *WeatherService) HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.Up})
+}
+
+// ReadyzHandler answers the readiness probe with the status of every component, 503 when a critical one is not up
+func (s *WeatherService) ReadyzHandler(c *gin.Context) {
+	report := s.health.Run(c.Request.Context())
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,220 @@
+package weatherservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/tracing"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client chosen key identifying retries of the same submission
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a previous request with the same key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// DefaultIdempotencyTTL is how long a stored response is replayed when no TTL is configured
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyClaimLease is how long a key stays in progress when its request never stores a response
+	idempotencyClaimLease = time.Minute
+	// maxIdempotencyKeyLength is the maximum length of an idempotency key
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers stored and replayed with the body
+var replayedHeaders = []string{"Content-Type", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+
+// responseRecorder copies the response body written by the handlers
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// IdempotencyMiddleware replays the stored response of a report submission when the same Idempotency-Key and body
+// come back within the TTL, and rejects a key reused with a different body. Keys are scoped to the report address,
+// only responses of requests that authenticated as that address are stored, and transient responses (429 and 5xx)
+// are not stored so that the client can retry them. A key whose request panics is released, and one whose response
+// could not be stored is freed once its claim lease expires.
+func (s *WeatherService) IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid idempotency key", nil)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Payloads without an address are rejected by the authentication, nothing needs to be stored
+		var payload WeatherReport
+		if err := json.Unmarshal(body, &payload); err != nil || payload.Address == "" {
+			c.Next()
+			return
+		}
+
+		hash := sha256.Sum256(body)
+		now := time.Now()
+		record := &db.IdempotencyRecord{
+			Address:     strings.ToLower(payload.Address),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash[:]),
+			ExpiresAt:   now.Add(idempotencyClaimLease).Unix(),
+		}
+
+		// The connection is released before the handlers run, they acquire their own
+		database, err := s.getDBConnection(c.Request.Context())
+		if err != nil {
+			s.internalServerError(c, err)
+			c.Abort()
+			return
+		}
+		existing, claimed, err := db.ClaimIdempotencyKey(database, record, now.Unix())
+		s.releaseDBConnection(database)
+		if err != nil {
+			s.internalServerError(c, err)
+			c.Abort()
+			return
+		}
+
+		if !claimed {
+			replayIdempotentResponse(c, existing, record.RequestHash)
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				s.releaseIdempotencyKey(c.Request.Context(), record)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		s.storeIdempotentResponse(c, record, recorder)
+	}
+}
+
+// replayIdempotentResponse writes the stored response of existing, or a conflict when it belongs to another body
+// or is still in progress
+func replayIdempotentResponse(c *gin.Context, existing *db.IdempotencyRecord, requestHash string) {
+	if existing.RequestHash != requestHash {
+		apierror.Abort(c, http.StatusConflict, CodeIdempotencyKeyReused, "Idempotency key was used with a different request", nil)
+		return
+	}
+	if existing.StatusCode == 0 {
+		apierror.Abort(c, http.StatusConflict, CodeIdempotencyKeyInProgress, "A request with this idempotency key is in progress", nil)
+		return
+	}
+
+	headers := map[string]string{}
+	if existing.Headers != "" {
+		_ = json.Unmarshal([]byte(existing.Headers), &headers)
+	}
+	for name, value := range headers {
+		c.Header(name, value)
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(existing.StatusCode, headers["Content-Type"], []byte(existing.Body))
+	c.Abort()
+}
+
+// transientStatus reports whether a response status may change on retry, such responses are not replayed
+func transientStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// replayableResponse reports whether the response may be stored for replays. The key is claimed before the
+// authentication with the address of the body, so the request must have authenticated as that member, and the
+// status must not change on retry.
+func replayableResponse(c *gin.Context, address string) bool {
+	membership, ok := c.Get("membership")
+	if !ok {
+		return false
+	}
+	m, ok := membership.(db.Membership)
+	if !ok || !strings.EqualFold(m.Address, address) {
+		return false
+	}
+	return !transientStatus(c.Writer.Status())
+}
+
+// storeIdempotentResponse stores the recorded response for replays, or releases the key after an unauthenticated
+// or transient response
+func (s *WeatherService) storeIdempotentResponse(c *gin.Context, record *db.IdempotencyRecord, recorder *responseRecorder) {
+	ctx := c.Request.Context()
+	if !replayableResponse(c, record.Address) {
+		s.releaseIdempotencyKey(ctx, record)
+		return
+	}
+
+	logger := s.logger.WithContext(ctx)
+	// The response is stored even when the client has gone
+	database, err := s.getDBConnection(tracing.Detach(ctx))
+	if err != nil {
+		logger.Errorf("Unable to store response of idempotency key %s: %v", record.Key, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	headers := map[string]string{}
+	for _, name := range replayedHeaders {
+		if value := recorder.Header().Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	encoded, _ := json.Marshal(headers)
+
+	record.StatusCode = recorder.Status()
+	record.Headers = string(encoded)
+	record.Body = recorder.body.String()
+	record.ExpiresAt = time.Now().Add(s.idempotencyTTL).Unix()
+	if err := db.CompleteIdempotencyKey(database, record); err != nil {
+		logger.Errorf("Unable to store response of idempotency key %s: %v", record.Key, err)
+	}
+}
+
+// releaseIdempotencyKey deletes the claim of the key so that the client can retry it right away
+func (s *WeatherService) releaseIdempotencyKey(ctx context.Context, record *db.IdempotencyRecord) {
+	logger := s.logger.WithContext(ctx)
+	// The key is released even when the client has gone
+	database, err := s.getDBConnection(tracing.Detach(ctx))
+	if err != nil {
+		logger.Errorf("Unable to release idempotency key %s: %v", record.Key, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	if err := db.ReleaseIdempotencyKey(database, record); err != nil {
+		logger.Errorf("Unable to release idempotency key %s: %v", record.Key, err)
+	}
+}
@@ -0,0 +1,147 @@
+package weatherservice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+)
+
+const testAddress = "0x36f3e6b9efb8e4874a4b43965ed73e077bca57c6"
+
+func TestTransientStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, false},
+		{http.StatusConflict, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		if got := transientStatus(tt.status); got != tt.want {
+			t.Errorf("transientStatus(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestReplayableResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		membership interface{} // Set by the authentication, nil when it failed
+		status     int
+		want       bool
+	}{
+		{name: "authenticated", membership: db.Membership{Address: "0x36F3e6b9eFB8E4874a4B43965eD73E077BCa57c6"}, status: http.StatusOK, want: true},
+		{name: "authenticated client error", membership: db.Membership{Address: testAddress}, status: http.StatusBadRequest, want: true},
+		{name: "rate limited", membership: db.Membership{Address: testAddress}, status: http.StatusTooManyRequests},
+		{name: "server error", membership: db.Membership{Address: testAddress}, status: http.StatusInternalServerError},
+		{name: "unauthenticated", status: http.StatusUnauthorized},
+		{name: "invalid signature", status: http.StatusBadRequest},
+		{name: "forbidden", status: http.StatusForbidden},
+		{name: "other member", membership: db.Membership{Address: "0x0000000000000000000000000000000000000001"}, status: http.StatusOK},
+		{name: "unexpected type", membership: &db.Membership{Address: testAddress}, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.membership != nil {
+				c.Set("membership", tt.membership)
+			}
+			c.Status(tt.status)
+			if got := replayableResponse(c, testAddress); got != tt.want {
+				t.Errorf("replayableResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayIdempotentResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stored := &db.IdempotencyRecord{
+		RequestHash: "hash",
+		StatusCode:  http.StatusOK,
+		Headers:     `{"Content-Type":"application/json; charset=utf-8","X-RateLimit-Remaining":"0"}`,
+		Body:        `{"id":7}`,
+	}
+	tests := []struct {
+		name     string
+		existing *db.IdempotencyRecord
+		hash     string
+		status   int
+		body     string // Substring of the response body
+		replayed bool
+	}{
+		{name: "stored response", existing: stored, hash: "hash", status: http.StatusOK, body: `{"id":7}`, replayed: true},
+		{name: "different body", existing: stored, hash: "other", status: http.StatusConflict, body: CodeIdempotencyKeyReused},
+		{name: "in progress", existing: &db.IdempotencyRecord{RequestHash: "hash"}, hash: "hash", status: http.StatusConflict, body: CodeIdempotencyKeyInProgress},
+		{name: "stored error", existing: &db.IdempotencyRecord{RequestHash: "hash", StatusCode: http.StatusNotFound, Body: "missing"}, hash: "hash", status: http.StatusNotFound, body: "missing", replayed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			replayIdempotentResponse(c, tt.existing, tt.hash)
+
+			if !c.IsAborted() {
+				t.Error("handlers were not aborted")
+			}
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.body)
+			}
+			if replayed := w.Header().Get(IdempotentReplayedHeader) == "true"; replayed != tt.replayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.replayed)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	replayIdempotentResponse(c, stored, "hash")
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want the stored header", got)
+	}
+}
+
+// TestIdempotencyMiddlewarePassThrough covers the requests that never claim a key
+func TestIdempotencyMiddlewarePassThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &WeatherService{}
+	tests := []struct {
+		name   string
+		key    string
+		body   string
+		status int
+	}{
+		{name: "no key", body: `{"address":"` + testAddress + `"}`, status: http.StatusNoContent},
+		{name: "key too long", key: strings.Repeat("k", maxIdempotencyKeyLength+1), body: `{}`, status: http.StatusBadRequest},
+		{name: "no address", key: "retry-1", body: `{"report":"21"}`, status: http.StatusNoContent},
+		{name: "invalid json", key: "retry-1", body: `{`, status: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/report", s.IdempotencyMiddleware(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+			req := httptest.NewRequest(http.MethodPost, "/report", strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set(IdempotencyKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
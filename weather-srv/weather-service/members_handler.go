@@ -0,0 +1,121 @@
+package weatherservice
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+)
+
+// recentTransitionsLimit is the number of status transitions returned with a member
+const recentTransitionsLimit = 10
+
+// MemberResponse is the state of a member as stored by the service
+type MemberResponse struct {
+	Address              string     `json:"address"`
+	Status               string     `json:"status"`
+	ChainName            string     `json:"chain_name"`
+	RegistrationContract string     `json:"registration_contract"`
+	Region               string     `json:"region"`
+	Reputation           float64    `json:"reputation"`
+	LastCall             int64      `json:"last_call"`
+	NextWindow           RateWindow `json:"next_window"`
+	// Suspension is the active off-chain suspension, Status stays the chain status while suspended
+	Suspension *SuspensionResponse `json:"suspension,omitempty"`
+}
+
+// SuspensionResponse is an active off-chain suspension of a member
+type SuspensionResponse struct {
+	Reason string `json:"reason"`
+	Until  int64  `json:"until,omitempty"` // Unix time the suspension expires, omitted until it is lifted
+}
+
+// MemberDetailResponse is a member with its report activity and recent status transitions
+type MemberDetailResponse struct {
+	MemberResponse
+	ReportCount       int64                `json:"report_count"`
+	ReportCount24h    int64                `json:"report_count_24h"`
+	RecentTransitions []TransitionResponse `json:"recent_transitions"`
+}
+
+// TransitionResponse is a status change of a member recorded from a contract event
+type TransitionResponse struct {
+	Event           string `json:"event"`
+	ChainName       string `json:"chain_name"`
+	BlockHeight     uint64 `json:"block_height"`
+	TransactionHash string `json:"transaction_hash"`
+	RecordedAt      int64  `json:"recorded_at"`
+}
+
+func toMemberResponse(m db.Membership, now int64) MemberResponse {
+	return MemberResponse{
+		Address:              m.Address,
+		Status:               m.Status,
+		ChainName:            m.ChainName,
+		RegistrationContract: m.RegistrationContract,
+		Region:               m.Region,
+		Reputation:           m.Reputation,
+		LastCall:             m.LastCall,
+		NextWindow:           policyFor(m).NextWindow(m.LastCall, now),
+		Suspension:           activeSuspension(m, now),
+	}
+}
+
+func toSuspensionResponse(m db.Membership) *SuspensionResponse {
+	return &SuspensionResponse{Reason: m.SuspensionReason, Until: m.SuspendedUntil}
+}
+
+// activeSuspension returns the suspension of the member when it is active at now
+func activeSuspension(m db.Membership, now int64) *SuspensionResponse {
+	if !m.IsSuspended(now) {
+		return nil
+	}
+	return toSuspensionResponse(m)
+}
+
+// MemberHandler returns the stored status, origin, activity and recent transitions of a member
+func (s *WeatherService) MemberHandler(c *gin.Context) {
+	member, err := s.LookupMember(c.Request.Context(), c.Param("address"))
+	if err != nil {
+		s.writeReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// MembersHandler lists the members ordered by ID, optionally filtered by the status query param.
+// The Suspended status lists the members with an active suspension.
+func (s *WeatherService) MembersHandler(c *gin.Context) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch db.MembershipStatus(status) {
+	case "", db.Unregistered, db.Registered, db.Resigned, db.Suspended:
+	default:
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid status", nil)
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	now := time.Now().Unix()
+	memberships, total, err := db.FindMemberships(database, status, now, limit, offset)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	members := make([]MemberResponse, len(memberships))
+	for i, m := range memberships {
+		members[i] = toMemberResponse(m, now)
+	}
+	c.JSON(http.StatusOK, gin.H{"members": members, "total": total, "limit": limit, "offset": offset})
+}
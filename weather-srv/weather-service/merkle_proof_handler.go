@@ -0,0 +1,158 @@
+package weatherservice
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/merkle"
+	"gorm.io/gorm"
+)
+
+// MerkleEpochResponse is the published Merkle root of an epoch
+type MerkleEpochResponse struct {
+	Epoch        int64  `json:"epoch"`
+	EpochSeconds int64  `json:"epoch_seconds"`
+	StartTime    int64  `json:"start_time"`
+	EndTime      int64  `json:"end_time"`
+	Root         string `json:"root"`
+	LeafCount    int    `json:"leaf_count"`
+}
+
+// MerkleProofResponse proves that a report is included in the root of its epoch
+type MerkleProofResponse struct {
+	ReportID        uint                `json:"report_id"`
+	TypedDataHash   string              `json:"typed_data_hash"`
+	ServerTimestamp int64               `json:"server_timestamp"`
+	Leaf            string              `json:"leaf"`
+	LeafIndex       int                 `json:"leaf_index"`
+	Proof           []string            `json:"proof"`
+	Epoch           MerkleEpochResponse `json:"epoch"`
+}
+
+func toMerkleEpochResponse(e *db.MerkleEpoch) MerkleEpochResponse {
+	return MerkleEpochResponse{
+		Epoch:        e.Epoch,
+		EpochSeconds: e.EpochSeconds,
+		StartTime:    e.StartTime,
+		EndTime:      e.EndTime,
+		Root:         e.Root,
+		LeafCount:    e.LeafCount,
+	}
+}
+
+// MerkleEpochHandler returns the committed Merkle root of an epoch
+func (s *WeatherService) MerkleEpochHandler(c *gin.Context) {
+	epoch, err := strconv.ParseInt(c.Param("epoch"), 10, 64)
+	if err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid epoch", nil)
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	merkleEpoch, err := db.FindMerkleEpoch(database, epoch)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(c, http.StatusNotFound, CodeEpochNotCommitted, "Epoch not committed", nil)
+			return
+		}
+		s.internalServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toMerkleEpochResponse(merkleEpoch))
+}
+
+// MerkleProofHandler returns the inclusion proof of a report against the root of its epoch
+func (s *WeatherService) MerkleProofHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid report id", nil)
+		return
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	report, err := db.FindWeatherReport(database, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(c, http.StatusNotFound, CodeReportNotFound, "Report not found", nil)
+			return
+		}
+		s.internalServerError(c, err)
+		return
+	}
+	if report.TypedDataHash == "" {
+		apierror.Write(c, http.StatusNotFound, CodeReportNotFound, "Report is not committed", nil)
+		return
+	}
+
+	// The stored leaf is authoritative, a report stored after its epoch was committed has none
+	committed, err := db.FindMerkleLeaf(database, report.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_, err = db.FindMerkleEpoch(database, s.committer.EpochOf(report.ServerTimestamp))
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			apierror.Write(c, http.StatusConflict, CodeEpochNotCommitted, "Epoch not committed yet", nil)
+		case err != nil:
+			s.internalServerError(c, err)
+		default:
+			apierror.Write(c, http.StatusConflict, CodeReportNotCommitted, "Report was stored after its epoch was committed", nil)
+		}
+		return
+	}
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+
+	merkleEpoch, err := db.FindMerkleEpoch(database, committed.Epoch)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	stored, err := db.FindMerkleLeaves(database, committed.Epoch)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	leaves := make([]common.Hash, len(stored))
+	for i, l := range stored {
+		leaves[i] = common.HexToHash(l.Leaf)
+	}
+	index := committed.LeafIndex
+	if index >= len(leaves) || merkle.Root(leaves) != common.HexToHash(merkleEpoch.Root) {
+		s.internalServerError(c, fmt.Errorf("stored leaves of merkle epoch %d do not match its root", merkleEpoch.Epoch))
+		return
+	}
+
+	proof := merkle.Proof(leaves, index)
+	hexProof := make([]string, len(proof))
+	for i, p := range proof {
+		hexProof[i] = p.Hex()
+	}
+	c.JSON(http.StatusOK, MerkleProofResponse{
+		ReportID:        report.ID,
+		TypedDataHash:   report.TypedDataHash,
+		ServerTimestamp: report.ServerTimestamp,
+		Leaf:            leaves[index].Hex(),
+		LeafIndex:       index,
+		Proof:           hexProof,
+		Epoch:           toMerkleEpochResponse(merkleEpoch),
+	})
+}
@@ -0,0 +1,96 @@
+package weatherservice
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+)
+
+// ObservationResponse is an aggregated observation of one metric within a window and region. Only WeightedMean is
+// weighted by the reputation of the reporters, the other aggregates treat every value alike. OutlierCount counts the
+// values flagged when their score window was aggregated, in every window size.
+type ObservationResponse struct {
+	WindowStart   int64   `json:"window_start"`
+	WindowEnd     int64   `json:"window_end"`
+	WindowSeconds int64   `json:"window_seconds"`
+	Region        string  `json:"region"`
+	Metric        string  `json:"metric"`
+	Count         int64   `json:"count"`
+	ReporterCount int64   `json:"reporter_count"`
+	Mean          float64 `json:"mean"`
+	WeightedMean  float64 `json:"weighted_mean"`
+	Median        float64 `json:"median"`
+	Min           float64 `json:"min"`
+	Max           float64 `json:"max"`
+	OutlierCount  int64   `json:"outlier_count"`
+}
+
+// ObservationsHandler returns the aggregated observations, filtered by the region, metric, window,
+// from and to (unix seconds) query params, newest window first
+func (s *WeatherService) ObservationsHandler(c *gin.Context) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+	query := db.ObservationQuery{
+		Region: c.Query("region"),
+		Metric: c.Query("metric"),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if window := c.Query("window"); window != "" {
+		seconds, err := strconv.ParseInt(window, 10, 64)
+		if err != nil || seconds <= 0 {
+			apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid window", nil)
+			return
+		}
+		query.WindowSeconds = seconds
+	}
+	for param, target := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := c.Query(param); value != "" {
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid "+param, nil)
+				return
+			}
+			*target = time.Unix(unix, 0)
+		}
+	}
+
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	rollups, err := db.FindObservationRollups(database, query)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	response := make([]ObservationResponse, len(rollups))
+	for i, r := range rollups {
+		response[i] = ObservationResponse{
+			WindowStart:   r.WindowStart.Unix(),
+			WindowEnd:     r.WindowStart.Unix() + r.WindowSeconds,
+			WindowSeconds: r.WindowSeconds,
+			Region:        r.Region,
+			Metric:        r.Metric,
+			Count:         r.Count,
+			ReporterCount: r.ReporterCount,
+			Mean:          r.Mean,
+			WeightedMean:  r.WeightedMean,
+			Median:        r.Median,
+			Min:           r.Min,
+			Max:           r.Max,
+			OutlierCount:  r.OutlierCount,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"observations": response, "limit": limit, "offset": offset})
+}
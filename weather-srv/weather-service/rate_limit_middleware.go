@@ -1,47 +1,112 @@
 package weatherservice
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
 	"github.com/wankhede04/blockswap.weather/weather-srv/db"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// DefaultRatePeriod is the number of seconds a member has to wait between two reports
+	DefaultRatePeriod int64 = 12
+	// DefaultRateWindow is the number of seconds a reporting window stays open once the period has elapsed
+	DefaultRateWindow int64 = 3
+)
+
+// RateLimitPolicy describes when a member is allowed to report
+type RateLimitPolicy struct {
+	Period int64 // Seconds between two window openings
+	Window int64 // Seconds a window stays open
+}
+
+// RateWindow is a reporting window expressed in unix seconds, Close is exclusive
+type RateWindow struct {
+	Open  int64 `json:"open"`
+	Close int64 `json:"close"`
+}
+
+// policyFor returns the rate limit policy of the member, falling back to the defaults
+func policyFor(m db.Membership) RateLimitPolicy {
+	policy := RateLimitPolicy{Period: DefaultRatePeriod, Window: DefaultRateWindow}
+	if m.RatePeriod > 0 {
+		policy.Period = m.RatePeriod
+	}
+	if m.RateWindow > 0 && m.RateWindow <= policy.Period {
+		policy.Window = m.RateWindow
+	}
+	return policy
+}
+
+// Allowed reports whether a member whose last call was lastCall may report at now
+func (p RateLimitPolicy) Allowed(lastCall, now int64) bool {
+	elapsed := now - lastCall
+	return elapsed >= p.Period && elapsed%p.Period < p.Window
+}
+
+// NextWindow returns the window containing now, or the next one to open after now
+func (p RateLimitPolicy) NextWindow(lastCall, now int64) RateWindow {
+	elapsed := now - lastCall
+	open := lastCall + p.Period
+	if elapsed >= p.Period {
+		open = lastCall + (elapsed/p.Period)*p.Period
+		if elapsed%p.Period >= p.Window {
+			open += p.Period
+		}
+	}
+	return RateWindow{Open: open, Close: open + p.Window}
+}
+
+// setRateLimitHeaders writes the X-RateLimit-* headers, a member gets one report per window
+func setRateLimitHeaders(c *gin.Context, remaining int, reset int64) {
+	c.Header("X-RateLimit-Limit", "1")
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+}
+
+// rejectRateLimited aborts the request with 429 and tells the client when the next window opens
+func rejectRateLimited(c *gin.Context, code, message string, next RateWindow, now int64) {
+	setRateLimitHeaders(c, 0, next.Open)
+	retryAfter := next.Open - now
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	apierror.Abort(c, http.StatusTooManyRequests, code, message, gin.H{"next_window": next})
+}
+
 // RateLimitMiddleware restricts user to call API within configured time frame
 func (s *WeatherService) RateLimitMiddleware() gin.HandlerFunc {
-	// Use a semaphore to limit concurrent requests
 	return func(c *gin.Context) {
-		s.semaphore.Add(1)
-		defer s.semaphore.Done()
-
 		membership, ok := c.Get("membership")
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized", nil)
 			return
 		}
 		m, ok := membership.(db.Membership)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized", nil)
 			return
 		}
 		currentTime := time.Now().Unix()
-
-		if currentTime-m.LastCall < 12 {
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+		if err := s.CheckRateLimit(m, currentTime); err != nil {
+			var reportErr *ReportError
+			if errors.As(err, &reportErr) && reportErr.NextWindow != nil {
+				rejectRateLimited(c, reportErr.Code, reportErr.Message, *reportErr.NextWindow, currentTime)
+				return
+			}
+			s.writeReportError(c, err)
 			c.Abort()
 			return
 		}
 
-		timeDifference := currentTime - m.LastCall
-		if timeDifference%12 > 2 {
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Window passed"})
-			c.Abort()
-			return
-		}
+		// The report consumes the open window, the next one opens a full period from now
+		setRateLimitHeaders(c, 0, currentTime+policyFor(m).Period)
 		c.Next()
 	}
 }
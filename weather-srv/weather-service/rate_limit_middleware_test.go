@@ -0,0 +1,95 @@
+package weatherservice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+)
+
+func TestPolicyFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		membership db.Membership
+		want       RateLimitPolicy
+	}{
+		{name: "defaults", want: RateLimitPolicy{Period: DefaultRatePeriod, Window: DefaultRateWindow}},
+		{name: "member override", membership: db.Membership{RatePeriod: 60, RateWindow: 10}, want: RateLimitPolicy{Period: 60, Window: 10}},
+		{name: "period only", membership: db.Membership{RatePeriod: 60}, want: RateLimitPolicy{Period: 60, Window: DefaultRateWindow}},
+		{name: "window longer than period", membership: db.Membership{RatePeriod: 5, RateWindow: 10}, want: RateLimitPolicy{Period: 5, Window: DefaultRateWindow}},
+		{name: "negative values", membership: db.Membership{RatePeriod: -1, RateWindow: -1}, want: RateLimitPolicy{Period: DefaultRatePeriod, Window: DefaultRateWindow}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policyFor(tt.membership); got != tt.want {
+				t.Errorf("policyFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitPolicyWindows(t *testing.T) {
+	const lastCall = 1000
+	policy := RateLimitPolicy{Period: 12, Window: 3}
+	tests := []struct {
+		name    string
+		now     int64
+		allowed bool
+		next    RateWindow
+	}{
+		{name: "right after the last call", now: 1000, next: RateWindow{Open: 1012, Close: 1015}},
+		{name: "before the first window", now: 1011, next: RateWindow{Open: 1012, Close: 1015}},
+		{name: "first window opens", now: 1012, allowed: true, next: RateWindow{Open: 1012, Close: 1015}},
+		{name: "last second of the first window", now: 1014, allowed: true, next: RateWindow{Open: 1012, Close: 1015}},
+		{name: "first window closed", now: 1015, next: RateWindow{Open: 1024, Close: 1027}},
+		{name: "later window", now: 1025, allowed: true, next: RateWindow{Open: 1024, Close: 1027}},
+		{name: "between later windows", now: 1030, next: RateWindow{Open: 1036, Close: 1039}},
+		{name: "clock behind the last call", now: 990, next: RateWindow{Open: 1012, Close: 1015}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Allowed(lastCall, tt.now); got != tt.allowed {
+				t.Errorf("Allowed() = %v, want %v", got, tt.allowed)
+			}
+			next := policy.NextWindow(lastCall, tt.now)
+			if next != tt.next {
+				t.Errorf("NextWindow() = %+v, want %+v", next, tt.next)
+			}
+			if inWindow := next.Open <= tt.now && tt.now < next.Close; inWindow != tt.allowed {
+				t.Errorf("now in NextWindow = %v, but Allowed = %v", inWindow, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestRejectRateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		now        int64
+		retryAfter string
+	}{
+		{name: "window ahead", now: 1000, retryAfter: "12"},
+		{name: "window already open", now: 1020, retryAfter: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			rejectRateLimited(c, apierror.CodeRateLimited, "Rate limited", RateWindow{Open: 1012, Close: 1015}, tt.now)
+
+			if w.Code != http.StatusTooManyRequests || !c.IsAborted() {
+				t.Errorf("status = %d, aborted = %v", w.Code, c.IsAborted())
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("X-RateLimit-Reset") != "1012" {
+				t.Errorf("X-RateLimit headers = %v", w.Header())
+			}
+		})
+	}
+}
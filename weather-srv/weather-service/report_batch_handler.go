@@ -0,0 +1,201 @@
+package weatherservice
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"github.com/wankhede04/blockswap.weather/weather-srv/webhook"
+	"gorm.io/gorm"
+)
+
+// MaxBatchSize is the maximum number of reports accepted in a single batch request
+const MaxBatchSize = 100
+
+// BatchItemResult is the outcome of a single report in a batch submission
+type BatchItemResult struct {
+	Index      int         `json:"index"`
+	ID         uint        `json:"id,omitempty"`
+	Address    string      `json:"address"`
+	Status     int         `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	Code       string      `json:"code,omitempty"`
+	NextWindow *RateWindow `json:"next_window,omitempty"`
+}
+
+// ReportWeatherBatchHandler verifies, rate limits and stores an array of individually signed reports.
+// Valid reports are inserted in a single transaction, every item gets its own result.
+func (s *WeatherService) ReportWeatherBatchHandler(c *gin.Context) {
+	var payload []WeatherReport
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+		return
+	}
+	if len(payload) == 0 || len(payload) > MaxBatchSize {
+		apierror.Write(c, http.StatusBadRequest, CodeBatchSize, fmt.Sprintf("Batch must contain between 1 and %d reports", MaxBatchSize), gin.H{"max_batch_size": MaxBatchSize})
+		return
+	}
+
+	results := make([]BatchItemResult, len(payload))
+	hashes := make([]string, len(payload))
+	members := make(map[string]*db.Membership)
+	var verified, accepted []int
+	currentTime := time.Now().Unix()
+
+	// Signatures are verified before a connection is acquired, EIP-1271 checks call the chain
+	for i, item := range payload {
+		results[i] = BatchItemResult{Index: i, Address: item.Address}
+
+		if err := s.verifyReport(c.Request.Context(), item); err != nil {
+			if !IsInvalidSignature(err) {
+				s.logger.WithContext(c.Request.Context()).Warnf("Unable to verify signature of %s: %v", item.Address, err)
+				unavailable := signatureCheckUnavailableError(err)
+				results[i].Status = unavailable.Status
+				results[i].Error = unavailable.Message
+				results[i].Code = unavailable.Code
+				continue
+			}
+			s.RecordInvalidSignature(c.Request.Context(), net.ParseIP(c.ClientIP()))
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "Error in verification"
+			results[i].Code = signatureErrorCode(err)
+			metrics.AuthFailures.WithLabelValues(results[i].Code).Inc()
+			continue
+		}
+		hash, err := s.typedDataHash(item)
+		if err != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "Error in verification"
+			results[i].Code = CodeTypedData
+			metrics.AuthFailures.WithLabelValues(results[i].Code).Inc()
+			continue
+		}
+		hashes[i] = hash
+		verified = append(verified, i)
+	}
+
+	if len(verified) == 0 {
+		c.JSON(http.StatusOK, gin.H{"accepted": 0, "results": results})
+		return
+	}
+
+	// Acquire a single database connection for the member lookups and the insert, its queries are traced under the request span
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	for _, i := range verified {
+		item := payload[i]
+
+		// A member may only use its window once, even within the same batch
+		if m, ok := members[item.Address]; ok {
+			next := policyFor(*m).NextWindow(currentTime, currentTime)
+			results[i].Status = http.StatusTooManyRequests
+			results[i].Error = "Too many requests"
+			results[i].Code = apierror.CodeRateLimited
+			metrics.RateLimitRejections.WithLabelValues(results[i].Code).Inc()
+			results[i].NextWindow = &next
+			continue
+		}
+
+		membership, err := findRegisteredMember(database, item.Address)
+		var suspended *ReportError
+		if errors.As(err, &suspended) {
+			results[i].Status = suspended.Status
+			results[i].Error = suspended.Message
+			results[i].Code = suspended.Code
+			metrics.AuthFailures.WithLabelValues(results[i].Code).Inc()
+			continue
+		}
+		if err != nil {
+			results[i].Status = http.StatusUnauthorized
+			results[i].Error = "Unauthorized"
+			results[i].Code = apierror.CodeUnauthorized
+			metrics.AuthFailures.WithLabelValues(results[i].Code).Inc()
+			continue
+		}
+
+		var rateErr *ReportError
+		if err := s.CheckRateLimit(membership, currentTime); errors.As(err, &rateErr) {
+			results[i].Status = rateErr.Status
+			results[i].Error = rateErr.Message
+			results[i].Code = rateErr.Code
+			results[i].NextWindow = rateErr.NextWindow
+			continue
+		}
+
+		members[item.Address] = &membership
+		accepted = append(accepted, i)
+	}
+
+	if len(accepted) > 0 {
+		reports, err := s.storeBatch(database, payload, hashes, accepted, members, currentTime)
+		if err != nil {
+			s.logger.WithContext(c.Request.Context()).Errorf("Unable to store report batch: %v", err)
+			for _, i := range accepted {
+				results[i].Status = http.StatusInternalServerError
+				results[i].Error = apierror.MessageInternal
+				results[i].Code = apierror.CodeInternal
+			}
+			accepted = nil
+		} else {
+			for n, i := range accepted {
+				results[i].Status = http.StatusOK
+				results[i].ID = reports[n].ID
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accepted": len(accepted), "results": results})
+}
+
+// storeBatch saves the accepted reports, their webhook deliveries and lastCall of their members in a single transaction, it returns the stored reports
+func (s *WeatherService) storeBatch(database *gorm.DB, payload []WeatherReport, hashes []string, accepted []int, members map[string]*db.Membership, currentTime int64) ([]db.WeatherReport, error) {
+	reports := make([]db.WeatherReport, len(accepted))
+	events := make([]interface{}, len(accepted))
+	tx := database.Begin()
+	for n, i := range accepted {
+		m := members[payload[i].Address]
+		reports[n] = db.WeatherReport{
+			MembershipID:    m.ID,
+			Report:          payload[i].Report,
+			Region:          reportRegion(payload[i].Report, *m),
+			TypedDataHash:   hashes[i],
+			ServerTimestamp: currentTime,
+		}
+		if err := tx.Create(&reports[n]).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := db.CreatePendingAggregation(tx, reports[n].ID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		events[n] = newReportEvent(reports[n], m.Address)
+		// Only last_call, a full save would undo suspensions, overrides and reputations stored since authentication
+		if err := db.UpdateMemberLastCall(tx, m.ID, currentTime); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		m.LastCall = currentTime
+	}
+	if err := s.webhooks.Enqueue(tx, webhook.EventReportCreated, events...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	s.sequencer.Notify()
+	return reports, nil
+}
@@ -0,0 +1,338 @@
+package weatherservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/aggregator"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"github.com/wankhede04/blockswap.weather/weather-srv/stream"
+	"github.com/wankhede04/blockswap.weather/weather-srv/tracing"
+	"github.com/wankhede04/blockswap.weather/weather-srv/webhook"
+	"gorm.io/gorm"
+)
+
+// ReportError is a failure of the shared service logic with the HTTP status it maps to.
+// The HTTP handlers and the gRPC server both translate it for their clients.
+type ReportError struct {
+	Status     int         // HTTP status of the failure
+	Code       string      // Stable error code returned to clients
+	Message    string      // Message returned to clients
+	Details    interface{} // Optional details returned to clients
+	NextWindow *RateWindow // Next reporting window of rate limited members
+	RetryAfter int64       // Seconds a throttled client has to wait, sent as Retry-After
+	Err        error
+}
+
+func (e *ReportError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *ReportError) Unwrap() error {
+	return e.Err
+}
+
+// internalError wraps an unexpected failure as a ReportError with status 500, the cause is not returned to clients.
+// A database connection that was not freed in time is a 503 instead.
+func internalError(err error) *ReportError {
+	if errors.Is(err, db.ErrAcquireTimeout) {
+		return databaseBusyError(err)
+	}
+	return &ReportError{Status: http.StatusInternalServerError, Code: apierror.CodeInternal, Message: apierror.MessageInternal, Err: err}
+}
+
+// AuthenticateReport verifies the signature of the report and loads the registered member that signed it.
+// It returns the member and the hex EIP-712 hash of the report.
+func (s *WeatherService) AuthenticateReport(ctx context.Context, report WeatherReport) (db.Membership, string, error) {
+	if err := s.verifyReport(ctx, report); err != nil {
+		if !IsInvalidSignature(err) {
+			s.logger.WithContext(ctx).Warnf("Unable to verify signature of %s: %v", report.Address, err)
+			return db.Membership{}, "", signatureCheckUnavailableError(err)
+		}
+		return db.Membership{}, "", authFailure(&ReportError{Status: http.StatusBadRequest, Code: signatureErrorCode(err), Message: "Error in verification", Err: err})
+	}
+
+	typedDataHash, err := s.typedDataHash(report)
+	if err != nil {
+		return db.Membership{}, "", authFailure(&ReportError{Status: http.StatusBadRequest, Code: CodeTypedData, Message: "Error in verification", Err: err})
+	}
+
+	database, err := s.getDBConnection(ctx)
+	if err != nil {
+		return db.Membership{}, "", internalError(err)
+	}
+	defer s.releaseDBConnection(database)
+
+	lookupCtx, span := tracing.Start(ctx, "report.lookupMember")
+	membership, err := findRegisteredMember(database.WithContext(lookupCtx), report.Address)
+	tracing.End(span, err)
+	var suspended *ReportError
+	if errors.As(err, &suspended) {
+		return db.Membership{}, "", authFailure(suspended)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, errMemberNotRegistered) {
+		return db.Membership{}, "", authFailure(&ReportError{Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized, Message: "Unauthorized", Err: err})
+	}
+	if err != nil {
+		return db.Membership{}, "", internalError(err)
+	}
+	return membership, typedDataHash, nil
+}
+
+// authFailure counts the rejected authentication by its code and returns it
+func authFailure(err *ReportError) *ReportError {
+	metrics.AuthFailures.WithLabelValues(err.Code).Inc()
+	return err
+}
+
+// CheckRateLimit returns a ReportError with status 429 and the next window when the member may not report at now
+func (s *WeatherService) CheckRateLimit(m db.Membership, now int64) error {
+	policy := policyFor(m)
+	next := policy.NextWindow(m.LastCall, now)
+
+	if now-m.LastCall < policy.Period {
+		return rateLimitedError(apierror.CodeRateLimited, "Too many requests", next)
+	}
+	if !policy.Allowed(m.LastCall, now) {
+		return rateLimitedError(apierror.CodeWindowPassed, "Window passed", next)
+	}
+	return nil
+}
+
+// rateLimitedError returns a ReportError with status 429 and the next window as details
+func rateLimitedError(code, message string, next RateWindow) *ReportError {
+	metrics.RateLimitRejections.WithLabelValues(code).Inc()
+	return &ReportError{
+		Status:     http.StatusTooManyRequests,
+		Code:       code,
+		Message:    message,
+		Details:    gin.H{"next_window": next},
+		NextWindow: &next,
+	}
+}
+
+// reportRegion returns the region named by the signed report, or the region of the member when it names none
+func reportRegion(report string, m db.Membership) string {
+	if region := aggregator.ReportRegion(report); region != "" {
+		return region
+	}
+	return m.Region
+}
+
+// StoreReport saves the report, its webhook deliveries and lastCall of the member in a single transaction, then
+// wakes the stream sequencer up
+func (s *WeatherService) StoreReport(ctx context.Context, m db.Membership, report, typedDataHash string, now int64) (db.WeatherReport, error) {
+	weatherReport := db.WeatherReport{
+		MembershipID:    m.ID,
+		Report:          report,
+		Region:          reportRegion(report, m),
+		TypedDataHash:   typedDataHash,
+		ServerTimestamp: now,
+	}
+
+	// Acquire a database connection
+	database, err := s.getDBConnection(ctx)
+	if err != nil {
+		return weatherReport, internalError(err)
+	}
+	defer s.releaseDBConnection(database)
+
+	if err := s.storeReportTx(ctx, database, &weatherReport, &m, now); err != nil {
+		return weatherReport, internalError(err)
+	}
+	s.sequencer.Notify()
+	return weatherReport, nil
+}
+
+// storeReportTx runs the transaction of StoreReport in its own span
+func (s *WeatherService) storeReportTx(ctx context.Context, database *gorm.DB, weatherReport *db.WeatherReport, m *db.Membership, now int64) (err error) {
+	ctx, span := tracing.Start(ctx, "report.store")
+	defer func() { tracing.End(span, err) }()
+
+	tx := database.WithContext(ctx).Begin()
+	if err := tx.Create(weatherReport).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := db.CreatePendingAggregation(tx, weatherReport.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.webhooks.Enqueue(tx, webhook.EventReportCreated, newReportEvent(*weatherReport, m.Address)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// Only last_call, a full save would undo suspensions, overrides and reputations stored since authentication
+	if err := db.UpdateMemberLastCall(tx, m.ID, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+	m.LastCall = now
+	_, commitSpan := tracing.Start(ctx, "report.commit")
+	err = tx.Commit().Error
+	tracing.End(commitSpan, err)
+	return err
+}
+
+// SubmitReport authenticates, rate limits and stores a single report, it returns the stored report and the
+// window opening a full period later
+func (s *WeatherService) SubmitReport(ctx context.Context, report WeatherReport) (db.WeatherReport, RateWindow, error) {
+	membership, typedDataHash, err := s.AuthenticateReport(ctx, report)
+	if err != nil {
+		return db.WeatherReport{}, RateWindow{}, err
+	}
+
+	currentTime := time.Now().Unix()
+	if err := s.CheckRateLimit(membership, currentTime); err != nil {
+		return db.WeatherReport{}, RateWindow{}, err
+	}
+
+	weatherReport, err := s.StoreReport(ctx, membership, report.Report, typedDataHash, currentTime)
+	if err != nil {
+		return weatherReport, RateWindow{}, err
+	}
+	// The report consumed the open window, the next one opens a full period from now
+	next := policyFor(membership).NextWindow(currentTime, currentTime)
+	return weatherReport, next, nil
+}
+
+// LookupMember returns the stored state, activity and recent transitions of the member with address
+func (s *WeatherService) LookupMember(ctx context.Context, address string) (MemberDetailResponse, error) {
+	if !common.IsHexAddress(address) {
+		return MemberDetailResponse{}, &ReportError{Status: http.StatusBadRequest, Code: apierror.CodeInvalidRequest, Message: "Invalid address"}
+	}
+	// Memberships are stored with the checksummed address emitted by the watcher
+	address = common.HexToAddress(address).Hex()
+
+	database, err := s.getDBConnection(ctx)
+	if err != nil {
+		return MemberDetailResponse{}, internalError(err)
+	}
+	defer s.releaseDBConnection(database)
+
+	membership, err := db.FindMemberShip(database, address)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return MemberDetailResponse{}, &ReportError{
+				Status:  http.StatusNotFound,
+				Code:    CodeMemberNotFound,
+				Message: "Member not found",
+				Details: gin.H{"status": string(db.Unregistered)},
+				Err:     err,
+			}
+		}
+		return MemberDetailResponse{}, internalError(err)
+	}
+
+	now := time.Now()
+	total, err := db.CountMemberReports(database, membership.ID, time.Time{})
+	if err != nil {
+		return MemberDetailResponse{}, internalError(err)
+	}
+	lastDay, err := db.CountMemberReports(database, membership.ID, now.Add(-24*time.Hour))
+	if err != nil {
+		return MemberDetailResponse{}, internalError(err)
+	}
+	transitions, err := db.FindMembershipTransitions(database, membership.Address, recentTransitionsLimit)
+	if err != nil {
+		return MemberDetailResponse{}, internalError(err)
+	}
+
+	recent := make([]TransitionResponse, len(transitions))
+	for i, t := range transitions {
+		recent[i] = TransitionResponse{
+			Event:           t.EventType,
+			ChainName:       t.ChainName,
+			BlockHeight:     t.BlockHeight,
+			TransactionHash: t.TransactionHash,
+			RecordedAt:      t.CreatedAt,
+		}
+	}
+	return MemberDetailResponse{
+		MemberResponse:    toMemberResponse(*membership, now.Unix()),
+		ReportCount:       total,
+		ReportCount24h:    lastDay,
+		RecentTransitions: recent,
+	}, nil
+}
+
+// QueryReports returns up to limit committed reports with an ID greater than afterID that match filter, oldest first
+func (s *WeatherService) QueryReports(ctx context.Context, afterID uint, filter stream.Filter, limit int) ([]stream.ReportEvent, error) {
+	database, err := s.getDBConnection(ctx)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer s.releaseDBConnection(database)
+
+	records, err := db.FindReportsAfter(database, afterID, filter.Address, filter.Region, limit)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]stream.ReportEvent, len(records))
+	for i, r := range records {
+		events[i] = stream.EventFromRecord(r)
+	}
+	return events, nil
+}
+
+// SubscribeReports subscribes to committed reports matching filter, they are published in stream sequence order.
+// Callers must release the subscriber with UnsubscribeReports.
+func (s *WeatherService) SubscribeReports(filter stream.Filter) *stream.Subscriber {
+	return s.broker.Subscribe(filter)
+}
+
+// ReplayReports sends the reports matching filter with a stream sequence greater than afterSequence, page by page
+// until it caught up, and returns the sequence live events must follow. Subscribe before replaying, so that no
+// report numbered in between is lost, and skip the live events up to the returned sequence.
+func (s *WeatherService) ReplayReports(ctx context.Context, filter stream.Filter, afterSequence uint64, send func(stream.ReportEvent) error) (uint64, error) {
+	for {
+		events, err := s.queryStream(ctx, filter, afterSequence)
+		if err != nil {
+			return afterSequence, err
+		}
+		for _, e := range events {
+			if err := send(e); err != nil {
+				return afterSequence, err
+			}
+			afterSequence = e.Sequence
+		}
+		if len(events) < streamReplayPage {
+			return afterSequence, nil
+		}
+	}
+}
+
+// queryStream returns a page of the reports matching filter with a stream sequence greater than afterSequence.
+// The connection is released before the page is sent, so slow clients do not hold it.
+func (s *WeatherService) queryStream(ctx context.Context, filter stream.Filter, afterSequence uint64) ([]stream.ReportEvent, error) {
+	database, err := s.getDBConnection(ctx)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer s.releaseDBConnection(database)
+
+	records, err := db.FindReportsAfterSequence(database, afterSequence, filter.Address, filter.Region, streamReplayPage)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]stream.ReportEvent, len(records))
+	for i, r := range records {
+		events[i] = stream.EventFromRecord(r)
+	}
+	return events, nil
+}
+
+// UnsubscribeReports releases a subscriber returned by SubscribeReports
+func (s *WeatherService) UnsubscribeReports(sub *stream.Subscriber) {
+	s.broker.Unsubscribe(sub)
+}
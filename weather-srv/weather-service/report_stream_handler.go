@@ -0,0 +1,207 @@
+package weatherservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/stream"
+)
+
+const (
+	// streamReplayPage is the number of missed reports read at once while replaying on reconnect
+	streamReplayPage = 500
+	// streamKeepAlive is the interval of SSE comments and WebSocket pings keeping idle connections open
+	streamKeepAlive = 30 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// newReportEvent returns the webhook data of a stored report, stream subscribers get it from the sequencer once
+// the report is numbered
+func newReportEvent(report db.WeatherReport, address string) stream.ReportEvent {
+	return stream.ReportEvent{
+		ID:        report.ID,
+		Address:   address,
+		Region:    report.Region,
+		Report:    report.Report,
+		CreatedAt: report.CreatedAt,
+	}
+}
+
+// ReportStreamHandler streams committed reports over WebSocket, or Server-Sent Events for plain HTTP requests.
+// Reports can be filtered with the address and region query params, clients resume after the sequence of the
+// last report received with the Last-Event-ID header or the last_event_id query param.
+func (s *WeatherService) ReportStreamHandler(c *gin.Context) {
+	filter := stream.Filter{Address: c.Query("address"), Region: c.Query("region")}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if id := c.Query("last_event_id"); id != "" {
+		lastEventID = id
+	}
+	var afterSequence uint64
+	if lastEventID != "" {
+		sequence, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid last event id", nil)
+			return
+		}
+		afterSequence = sequence
+	}
+
+	sub := s.SubscribeReports(filter)
+	defer s.UnsubscribeReports(sub)
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		s.streamWebSocket(c, sub, filter, afterSequence)
+		return
+	}
+	s.streamSSE(c, sub, filter, afterSequence)
+}
+
+// writeSSEEvent writes the report as a Server-Sent Event with its stream sequence as event id
+func writeSSEEvent(w io.Writer, e stream.ReportEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: report\ndata: %s\n\n", e.Sequence, data)
+	return err
+}
+
+// streamSSE replays the reports after afterSequence, when given, and writes the live reports as Server-Sent Events
+// until the client disconnects
+func (s *WeatherService) streamSSE(c *gin.Context, sub *stream.Subscriber, filter stream.Filter, afterSequence uint64) {
+	w := c.Writer
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+	}
+	write := func(e stream.ReportEvent) error {
+		start()
+		if err := writeSSEEvent(w, e); err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	}
+
+	replayed := afterSequence
+	if afterSequence > 0 {
+		var err error
+		replayed, err = s.ReplayReports(c.Request.Context(), filter, afterSequence, write)
+		if err != nil {
+			// The client resumes after the last report written
+			if !started {
+				s.internalServerError(c, fmt.Errorf("unable to replay reports after %d: %w", afterSequence, err))
+			} else if c.Request.Context().Err() == nil {
+				s.logger.WithContext(c.Request.Context()).Errorf("Unable to replay reports after %d: %v", afterSequence, err)
+			}
+			return
+		}
+	}
+	start()
+	w.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if e.Sequence <= replayed {
+				continue
+			}
+			if err := write(e); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// streamWebSocket replays the reports after afterSequence, when given, and writes the live reports as JSON messages
+// on a WebSocket until the client disconnects
+func (s *WeatherService) streamWebSocket(c *gin.Context, sub *stream.Subscriber, filter stream.Filter, afterSequence uint64) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		s.logger.WithContext(c.Request.Context()).Errorf("Unable to upgrade report stream to websocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Drain incoming frames so close and pong messages are handled
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	replayed := afterSequence
+	if afterSequence > 0 {
+		replayed, err = s.ReplayReports(c.Request.Context(), filter, afterSequence, func(e stream.ReportEvent) error {
+			return conn.WriteJSON(e)
+		})
+		if err != nil {
+			// The client resumes after the last report written
+			if c.Request.Context().Err() == nil {
+				s.logger.WithContext(c.Request.Context()).Errorf("Unable to replay reports after %d: %v", afterSequence, err)
+			}
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "Unable to replay reports"), time.Now().Add(time.Second))
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if e.Sequence <= replayed {
+				continue
+			}
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
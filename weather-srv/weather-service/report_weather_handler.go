@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
 	"github.com/wankhede04/blockswap.weather/weather-srv/db"
 )
 
@@ -12,53 +13,32 @@ import (
 func (s *WeatherService) ReportWeatherHandler(c *gin.Context) {
 	report, ok := c.Get("report")
 	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Report not found"})
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Report not found", nil)
 		return
 	}
 	reportStr, ok := report.(string)
 	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report format"})
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid report format", nil)
 		return
 	}
 
 	membership, ok := c.Get("membership")
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Write(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized", nil)
 		return
 	}
 	m, ok := membership.(db.Membership)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Write(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized", nil)
 		return
 	}
 
-	weatherReport := db.WeatherReport{MembershipID: m.ID, Report: reportStr}
-
-	// Acquire a database connection
-	db, err := s.getDBConnection()
+	typedDataHash := c.GetString("typed_data_hash")
+	weatherReport, err := s.StoreReport(c.Request.Context(), m, reportStr, typedDataHash, time.Now().Unix())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer s.releaseDBConnection(db)
-
-	// Save the weather report and update the last call time in a single transaction
-	tx := db.Begin()
-	if err := tx.Create(&weatherReport).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	m.LastCall = time.Now().Unix()
-	if err := tx.Save(&m).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		s.writeReportError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Weather reported successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Weather reported successfully", "id": weatherReport.ID})
 }
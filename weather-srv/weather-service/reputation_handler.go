@@ -0,0 +1,60 @@
+package weatherservice
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"gorm.io/gorm"
+)
+
+// ReputationResponse is the reputation of a member over its recent scored values
+type ReputationResponse struct {
+	Address    string  `json:"address"`
+	Reputation float64 `json:"reputation"`
+	Scored     int64   `json:"scored"`
+	Outliers   int64   `json:"outliers"`
+	Window     int     `json:"window"`
+}
+
+// ReputationHandler returns the reputation of the member with the given address
+func (s *WeatherService) ReputationHandler(c *gin.Context) {
+	database, err := s.getDBConnection(c.Request.Context())
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	defer s.releaseDBConnection(database)
+
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid address", nil)
+		return
+	}
+	membership, err := db.FindMemberShip(database, common.HexToAddress(address).Hex())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(c, http.StatusNotFound, CodeMemberNotFound, "Member not found", nil)
+			return
+		}
+		s.internalServerError(c, err)
+		return
+	}
+
+	window := s.aggregator.ReputationWindow()
+	summary, err := db.SummarizeRecentScores(database, membership.ID, window)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ReputationResponse{
+		Address:    membership.Address,
+		Reputation: membership.Reputation,
+		Scored:     summary.Scored,
+		Outliers:   summary.Outliers,
+		Window:     window,
+	})
+}
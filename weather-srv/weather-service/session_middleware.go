@@ -0,0 +1,96 @@
+package weatherservice
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/auth"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"github.com/wankhede04/blockswap.weather/weather-srv/stream"
+)
+
+// sessionAddressKey is the gin context key of the address of the signed in member
+const sessionAddressKey = "session_address"
+
+// SessionMiddleware requires a valid access token in the Authorization header and exposes its address to the handlers
+func (s *WeatherService) SessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.sessionsEnabled(c) {
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || token == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Missing access token", nil)
+			return
+		}
+
+		claims, err := s.sessions.VerifyAccessToken(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			if errors.Is(err, auth.ErrTokenExpired) {
+				metrics.AuthFailures.WithLabelValues(CodeTokenExpired).Inc()
+				apierror.Abort(c, http.StatusUnauthorized, CodeTokenExpired, "Access token expired", nil)
+				return
+			}
+			metrics.AuthFailures.WithLabelValues(CodeTokenInvalid).Inc()
+			apierror.Abort(c, http.StatusUnauthorized, CodeTokenInvalid, "Invalid access token", nil)
+			return
+		}
+
+		// The membership is checked on every request, a member that resigned or was suspended loses access before
+		// its access token expires
+		database, err := s.getDBConnection(c.Request.Context())
+		if err != nil {
+			s.internalServerError(c, err)
+			c.Abort()
+			return
+		}
+		ok := s.checkSessionMember(c, database, claims.Subject)
+		s.releaseDBConnection(database)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		c.Set(sessionAddressKey, claims.Subject)
+		c.Next()
+	}
+}
+
+// MeHandler returns the member of the session, like MemberHandler
+func (s *WeatherService) MeHandler(c *gin.Context) {
+	member, err := s.LookupMember(c.Request.Context(), c.GetString(sessionAddressKey))
+	if err != nil {
+		s.writeReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// MyReportsHandler returns the reports of the member of the session after the after_id query param, oldest first
+func (s *WeatherService) MyReportsHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxListLimit {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid limit", nil)
+		return
+	}
+	afterID, err := strconv.ParseUint(c.DefaultQuery("after_id", "0"), 10, 64)
+	if err != nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid after id", nil)
+		return
+	}
+
+	reports, err := s.QueryReports(c.Request.Context(), uint(afterID), stream.Filter{Address: c.GetString(sessionAddressKey)}, limit)
+	if err != nil {
+		s.internalServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
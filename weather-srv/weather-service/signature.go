@@ -0,0 +1,103 @@
+package weatherservice
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signature error codes returned to clients
+const (
+	CodeSignatureEncoding   = "signature_encoding"    // Signature is not valid 0x prefixed hex
+	CodeSignatureLength     = "signature_length"      // Signature is neither 65 bytes nor 64 bytes (EIP-2098)
+	CodeSignatureRecoveryID = "signature_recovery_id" // V is not one of 0, 1, 27 or 28
+	CodeSignatureHighS      = "signature_high_s"      // S is in the upper half of the curve order
+	CodeSignatureRecovery   = "signature_recovery"    // Public key could not be recovered
+	CodeSignerMismatch      = "signer_mismatch"       // Recovered signer is not the report address
+	CodeSignatureScheme     = "signature_scheme"      // Signature scheme is not supported
+	CodeSignatureRejected   = "signature_rejected"    // Contract account rejected the signature
+	CodeTypedData           = "typed_data"            // Report could not be encoded as EIP-712 typed data
+)
+
+// SignatureError is a signature verification failure with a stable code
+type SignatureError struct {
+	Code string
+	Err  error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Code, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// signatureErrorCode returns the code of a SignatureError, or signature_recovery for any other error
+func signatureErrorCode(err error) string {
+	var sigErr *SignatureError
+	if errors.As(err, &sigErr) {
+		return sigErr.Code
+	}
+	return CodeSignatureRecovery
+}
+
+// compactSignatureLength is the length of an EIP-2098 compact signature
+const compactSignatureLength = 64
+
+// secp256k1HalfN is half the order of the secp256k1 curve, valid signatures have s <= secp256k1HalfN
+var secp256k1HalfN = new(big.Int).Rsh(crypto.S256().Params().N, 1)
+
+// DecodeSignature strictly decodes a hex signature into the 65 byte [R || S || V] form with V in {0, 1}.
+// It accepts 65 byte signatures with V in {0, 1, 27, 28} and 64 byte EIP-2098 compact signatures, and rejects high s values.
+func DecodeSignature(signature string) ([]byte, error) {
+	raw, err := hexutil.Decode(signature)
+	if err != nil {
+		return nil, &SignatureError{Code: CodeSignatureEncoding, Err: err}
+	}
+
+	sign := make([]byte, crypto.SignatureLength)
+	switch len(raw) {
+	case crypto.SignatureLength:
+		copy(sign, raw)
+		v := sign[crypto.RecoveryIDOffset]
+		if v >= 27 {
+			v -= 27 // Transform V from 27/28 to 0/1 according to the yellow paper
+		}
+		if v > 1 {
+			return nil, &SignatureError{Code: CodeSignatureRecoveryID, Err: fmt.Errorf("invalid recovery id %d", raw[crypto.RecoveryIDOffset])}
+		}
+		sign[crypto.RecoveryIDOffset] = v
+	case compactSignatureLength:
+		// EIP-2098: the top bit of the second word holds the y parity
+		copy(sign, raw)
+		sign[crypto.RecoveryIDOffset] = sign[32] >> 7
+		sign[32] &= 0x7f
+	default:
+		return nil, &SignatureError{Code: CodeSignatureLength, Err: fmt.Errorf("invalid signature length %d", len(raw))}
+	}
+
+	s := new(big.Int).SetBytes(sign[32:64])
+	if s.Sign() == 0 || s.Cmp(secp256k1HalfN) > 0 {
+		return nil, &SignatureError{Code: CodeSignatureHighS, Err: errors.New("s value out of range")}
+	}
+	return sign, nil
+}
+
+// RecoverSigner recovers the address that signed hash
+func RecoverSigner(hash []byte, signature string) (common.Address, error) {
+	sign, err := DecodeSignature(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pubKey, err := crypto.SigToPub(hash, sign)
+	if err != nil {
+		return common.Address{}, &SignatureError{Code: CodeSignatureRecovery, Err: err}
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
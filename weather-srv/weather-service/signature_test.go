@@ -0,0 +1,108 @@
+package weatherservice
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// signWithParity signs hashes until the signature has the recovery id v, it returns the hash and the 65 byte signature
+func signWithParity(t *testing.T, v byte) ([]byte, []byte) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 256; i++ {
+		hash := crypto.Keccak256([]byte{byte(i)})
+		sig, err := crypto.Sign(hash, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig[crypto.RecoveryIDOffset] == v {
+			return hash, sig
+		}
+	}
+	t.Fatalf("no signature with recovery id %d", v)
+	return nil, nil
+}
+
+// compact returns the EIP-2098 form of a 65 byte signature with V in {0, 1}
+func compact(sig []byte) []byte {
+	out := append([]byte(nil), sig[:64]...)
+	out[32] |= sig[crypto.RecoveryIDOffset] << 7
+	return out
+}
+
+// withV returns a copy of sig with its recovery id replaced
+func withV(sig []byte, v byte) []byte {
+	out := append([]byte(nil), sig...)
+	out[crypto.RecoveryIDOffset] = v
+	return out
+}
+
+// highS returns the malleable twin of sig, with s replaced by N - s and the recovery id flipped
+func highS(sig []byte) []byte {
+	out := append([]byte(nil), sig...)
+	s := new(big.Int).SetBytes(sig[32:64])
+	new(big.Int).Sub(crypto.S256().Params().N, s).FillBytes(out[32:64])
+	out[crypto.RecoveryIDOffset] ^= 1
+	return out
+}
+
+func TestDecodeSignature(t *testing.T) {
+	hash0, sig0 := signWithParity(t, 0)
+	hash1, sig1 := signWithParity(t, 1)
+	zeroS := append([]byte(nil), sig0...)
+	copy(zeroS[32:64], make([]byte, 32))
+
+	tests := []struct {
+		name      string
+		signature string
+		hash      []byte
+		want      []byte // Expected [R || S || V] with V in {0, 1}, nil when an error is expected
+		code      string
+	}{
+		{name: "v 0", signature: hexutil.Encode(sig0), hash: hash0, want: sig0},
+		{name: "v 1", signature: hexutil.Encode(sig1), hash: hash1, want: sig1},
+		{name: "v 27", signature: hexutil.Encode(withV(sig0, 27)), hash: hash0, want: sig0},
+		{name: "v 28", signature: hexutil.Encode(withV(sig1, 28)), hash: hash1, want: sig1},
+		{name: "compact with even y", signature: hexutil.Encode(compact(sig0)), hash: hash0, want: sig0},
+		{name: "compact with odd y", signature: hexutil.Encode(compact(sig1)), hash: hash1, want: sig1},
+		{name: "v 2", signature: hexutil.Encode(withV(sig0, 2)), code: CodeSignatureRecoveryID},
+		{name: "v 29", signature: hexutil.Encode(withV(sig0, 29)), code: CodeSignatureRecoveryID},
+		{name: "high s", signature: hexutil.Encode(highS(sig0)), code: CodeSignatureHighS},
+		{name: "high s with v 28", signature: hexutil.Encode(withV(highS(sig0), 28)), code: CodeSignatureHighS},
+		{name: "zero s", signature: hexutil.Encode(zeroS), code: CodeSignatureHighS},
+		{name: "too short", signature: hexutil.Encode(sig0[:63]), code: CodeSignatureLength},
+		{name: "too long", signature: hexutil.Encode(append(sig0, 0)), code: CodeSignatureLength},
+		{name: "no 0x prefix", signature: hexutil.Encode(sig0)[2:], code: CodeSignatureEncoding},
+		{name: "not hex", signature: "0xzz", code: CodeSignatureEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeSignature(tt.signature)
+			if tt.code != "" {
+				var sigErr *SignatureError
+				if !errors.As(err, &sigErr) || sigErr.Code != tt.code {
+					t.Fatalf("DecodeSignature() error = %v, want code %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeSignature() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("DecodeSignature() = %x, want %x", got, tt.want)
+			}
+			if _, err := crypto.SigToPub(tt.hash, got); err != nil {
+				t.Fatalf("decoded signature does not recover: %v", err)
+			}
+		})
+	}
+}
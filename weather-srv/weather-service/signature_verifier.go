@@ -0,0 +1,119 @@
+package weatherservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/wankhede04/blockswap.weather/weather-srv/tracing"
+	"github.com/wankhede04/blockswap.weather/weather-srv/worker"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SignatureScheme selects how the signature of a weather report is verified
+type SignatureScheme string
+
+const (
+	SchemeEIP712  SignatureScheme = "eip712"  // EIP-712 typed data signed by an EOA
+	SchemeEIP191  SignatureScheme = "eip191"  // EIP-191 personal_sign signed by an EOA
+	SchemeEIP1271 SignatureScheme = "eip1271" // EIP-712 typed data validated by a smart contract wallet
+)
+
+// SignatureVerifier verifies that a weather report was signed by its address
+type SignatureVerifier interface {
+	Verify(ctx context.Context, report WeatherReport) error
+}
+
+// EIP712Verifier verifies EIP-712 typed data signatures from EOAs
+type EIP712Verifier struct {
+	worker *worker.Worker
+}
+
+// Verify implements SignatureVerifier
+func (v *EIP712Verifier) Verify(_ context.Context, report WeatherReport) error {
+	return VerifyOrderSignature(report, v.worker.GetChainID(), v.worker.GetRegistrationContract().String())
+}
+
+// EIP191Verifier verifies personal_sign signatures over PersonalSignMessage from EOAs
+type EIP191Verifier struct {
+	worker *worker.Worker
+}
+
+// Verify implements SignatureVerifier
+func (v *EIP191Verifier) Verify(_ context.Context, report WeatherReport) error {
+	message := PersonalSignMessage(report, v.worker.GetChainID(), v.worker.GetRegistrationContract().String())
+
+	return VerifySigner(accounts.TextHash([]byte(message)), report.Signature, report.Address)
+}
+
+// EIP1271Verifier verifies EIP-712 typed data signatures through isValidSignature of a contract account.
+// Failures to reach the RPC provider are returned as is, not as a SignatureError.
+type EIP1271Verifier struct {
+	worker *worker.Worker
+}
+
+// Verify implements SignatureVerifier
+func (v *EIP1271Verifier) Verify(ctx context.Context, report WeatherReport) error {
+	if !common.IsHexAddress(report.Address) {
+		return &SignatureError{Code: CodeSignerMismatch, Err: fmt.Errorf("invalid address %s", report.Address)}
+	}
+
+	hash, err := EncodeOrderStruct(report, v.worker.GetChainID(), v.worker.GetRegistrationContract().String())
+	if err != nil {
+		return &SignatureError{Code: CodeTypedData, Err: err}
+	}
+	// Contract wallets define their own signature format, only the hex encoding is checked here
+	sign, err := hexutil.Decode(report.Signature)
+	if err != nil {
+		return &SignatureError{Code: CodeSignatureEncoding, Err: err}
+	}
+
+	valid, err := v.worker.IsValidSignature(ctx, common.HexToAddress(report.Address), common.BytesToHash(hash), sign)
+	if errors.Is(err, worker.ErrNotContractAccount) {
+		return &SignatureError{Code: CodeSignatureRejected, Err: err}
+	}
+	if err != nil {
+		// The RPC provider could not be asked, the signature may well be valid
+		return err
+	}
+	if !valid {
+		return &SignatureError{Code: CodeSignatureRejected, Err: fmt.Errorf("contract rejected signature")}
+	}
+	return nil
+}
+
+// PersonalSignMessage returns the message members sign with personal_sign when using the eip191 scheme
+func PersonalSignMessage(report WeatherReport, chainID int64, registrationContract string) string {
+	return fmt.Sprintf("WeatherReport\nchainId: %d\nverifyingContract: %s\naddress: %s\nreport: %s",
+		chainID, registrationContract, report.Address, report.Report)
+}
+
+// newSignatureVerifiers returns the verifier of every supported scheme
+func newSignatureVerifiers(wkr *worker.Worker) map[SignatureScheme]SignatureVerifier {
+	return map[SignatureScheme]SignatureVerifier{
+		SchemeEIP712:  &EIP712Verifier{worker: wkr},
+		SchemeEIP191:  &EIP191Verifier{worker: wkr},
+		SchemeEIP1271: &EIP1271Verifier{worker: wkr},
+	}
+}
+
+// verifyReport verifies the signature of the report with the scheme it selects, the scheme is required so that a
+// signature is never checked against a scheme its signer did not mean
+func (s *WeatherService) verifyReport(ctx context.Context, report WeatherReport) (err error) {
+	scheme := SignatureScheme(strings.ToLower(report.SignatureScheme))
+	if scheme == "" {
+		return &SignatureError{Code: CodeSignatureScheme, Err: errors.New("signature scheme is required")}
+	}
+	ctx, span := tracing.Start(ctx, "report.verifySignature", attribute.String("signature.scheme", string(scheme)))
+	defer func() { tracing.End(span, err) }()
+
+	verifier, ok := s.verifiers[scheme]
+	if !ok {
+		return &SignatureError{Code: CodeSignatureScheme, Err: fmt.Errorf("unsupported signature scheme %s", report.SignatureScheme)}
+	}
+	return verifier.Verify(ctx, report)
+}
@@ -0,0 +1,134 @@
+package weatherservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wankhede04/blockswap.weather/weather-srv/apierror"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"github.com/wankhede04/blockswap.weather/weather-srv/throttle"
+)
+
+// PreAuthMiddleware rejects banned clients, throttles clients per IP and subnet and limits the request body size,
+// before the body is parsed or any signature is recovered
+func (s *WeatherService) PreAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.ThrottleClient(net.ParseIP(c.ClientIP())); err != nil {
+			s.writeReportError(c, err)
+			c.Abort()
+			return
+		}
+
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		maxBodyBytes := s.throttle.Config().MaxBodyBytes
+		if c.Request.ContentLength > maxBodyBytes {
+			s.rejectBodyTooLarge(c, maxBodyBytes)
+			return
+		}
+		// Bodies without a length are read here, so that no later reader has to handle the limit
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				s.rejectBodyTooLarge(c, maxBodyBytes)
+				return
+			}
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request payload", nil)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+// ThrottleClient takes a token from the buckets of the client IP and its subnet, it returns a ReportError with
+// status 403 for banned clients and 429 when a bucket is empty. Requests without a client IP are not throttled.
+func (s *WeatherService) ThrottleClient(ip net.IP) error {
+	if ip == nil {
+		return nil
+	}
+	decision, wait := s.throttle.Allow(ip, time.Now())
+	retryAfter := int64(math.Ceil(wait.Seconds()))
+	switch decision {
+	case throttle.Banned:
+		metrics.RateLimitRejections.WithLabelValues("banned").Inc()
+		return &ReportError{
+			Status:     http.StatusForbidden,
+			Code:       CodeClientBanned,
+			Message:    "Client banned after repeated invalid signatures",
+			RetryAfter: retryAfter,
+		}
+	case throttle.IPLimited, throttle.SubnetLimited:
+		scope := "ip"
+		if decision == throttle.SubnetLimited {
+			scope = "subnet"
+		}
+		metrics.RateLimitRejections.WithLabelValues(scope).Inc()
+		return &ReportError{
+			Status:     http.StatusTooManyRequests,
+			Code:       apierror.CodeRateLimited,
+			Message:    "Too many requests",
+			Details:    gin.H{"scope": scope},
+			RetryAfter: retryAfter,
+		}
+	}
+	return nil
+}
+
+// RecordInvalidSignature counts a failed report authentication of the client IP towards its ban
+func (s *WeatherService) RecordInvalidSignature(ctx context.Context, ip net.IP) {
+	if ip == nil {
+		return
+	}
+	if until, banned := s.throttle.RecordInvalidSignature(ip, time.Now()); banned {
+		s.logger.WithContext(ctx).Warnf("Banned client %s until %s after repeated invalid signatures", ip, until.Format(time.RFC3339))
+	}
+}
+
+// MaxBodyBytes returns the maximum request body size
+func (s *WeatherService) MaxBodyBytes() int64 {
+	return s.throttle.Config().MaxBodyBytes
+}
+
+// IsInvalidSignature reports whether err rejected a signature, only those count towards a ban. Unknown or suspended
+// members and database or RPC failures do not, so that an outage never bans honest clients.
+func IsInvalidSignature(err error) bool {
+	var sigErr *SignatureError
+	return errors.As(err, &sigErr)
+}
+
+func (s *WeatherService) rejectBodyTooLarge(c *gin.Context, maxBodyBytes int64) {
+	s.throttle.RecordBodyTooLarge()
+	metrics.RateLimitRejections.WithLabelValues("body_too_large").Inc()
+	apierror.Abort(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "Request body too large", gin.H{"max_bytes": maxBodyBytes})
+}
+
+// ThrottleStatsHandler returns the pre-authentication throttling counters and the active bans
+func (s *WeatherService) ThrottleStatsHandler(c *gin.Context) {
+	now := time.Now()
+	c.JSON(http.StatusOK, gin.H{"stats": s.throttle.Stats(now), "bans": s.throttle.Bans(now)})
+}
+
+// UnbanClientHandler lifts the ban of the client IP in the path
+func (s *WeatherService) UnbanClientHandler(c *gin.Context) {
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		apierror.Write(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid IP", nil)
+		return
+	}
+	if !s.throttle.Unban(ip) {
+		apierror.Write(c, http.StatusNotFound, apierror.CodeNotFound, "Client is not banned", nil)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
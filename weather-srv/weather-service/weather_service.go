@@ -1,10 +1,21 @@
 package weatherservice
 
 import (
-	"sync"
+	"context"
+	"time"
 
+	"github.com/wankhede04/blockswap.weather/weather-srv/aggregator"
+	"github.com/wankhede04/blockswap.weather/weather-srv/auth"
+	"github.com/wankhede04/blockswap.weather/weather-srv/concurrency"
 	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/health"
+	"github.com/wankhede04/blockswap.weather/weather-srv/merkle"
+	"github.com/wankhede04/blockswap.weather/weather-srv/metrics"
+	"github.com/wankhede04/blockswap.weather/weather-srv/stream"
+	"github.com/wankhede04/blockswap.weather/weather-srv/throttle"
+	"github.com/wankhede04/blockswap.weather/weather-srv/tracing"
 	"github.com/wankhede04/blockswap.weather/weather-srv/watcher"
+	"github.com/wankhede04/blockswap.weather/weather-srv/webhook"
 	"github.com/wankhede04/blockswap.weather/weather-srv/worker"
 
 	"github.com/sirupsen/logrus"
@@ -12,75 +23,136 @@ import (
 )
 
 type WeatherService struct {
-	worker    *worker.Worker
-	watcher   *watcher.WatcherSRV
-	Database  *db.PostgresDataBase
-	logger    *logrus.Logger
-	dbPool    *db.ConnectionPool // Custom connection pool
-	dbMutex   sync.RWMutex       // Mutex for database connection synchronization
-	semaphore *sync.WaitGroup
+	worker     *worker.Worker
+	watcher    *watcher.WatcherSRV
+	Database   *db.PostgresDataBase
+	logger     *logrus.Logger
+	verifiers  map[SignatureScheme]SignatureVerifier
+	broker     *stream.Broker
+	sequencer  *stream.Sequencer // Numbers committed reports and publishes them to the broker
+	webhooks   *webhook.Dispatcher
+	aggregator *aggregator.Aggregator
+	committer  *merkle.Committer
+	adminKey   string
+	sessions   *auth.TokenIssuer // Nil when sign-in sessions are disabled
+	throttle   *throttle.Limiter // Pre-authentication throttling of the clients
+	health     *health.Checker   // Readiness checks of the components
+
+	reportLimiter *concurrency.Limiter // Bounds the reports processed at the same time
+
+	idempotencyTTL time.Duration
 }
 
-func NewWeatherService(dbURL string, logger *logrus.Logger, cfg *worker.WorkerConfig, maxConcurrentConnections int) (*WeatherService, error) {
-	database, err := db.InitialMigration(dbURL, logger)
+// Options holds the dependencies and settings of a WeatherService and of the jobs it runs
+type Options struct {
+	DatabaseURL    string        // PostgreSQL connection URL, the database is migrated on creation
+	Pool           db.PoolConfig // Connections shared by the API and the watcher
+	Logger         *logrus.Logger
+	Worker         worker.WorkerConfig // Chain and registration contract watched for membership changes
+	Webhooks       webhook.Config
+	Stream         stream.Config
+	Aggregation    aggregator.Config
+	Merkle         merkle.Config
+	Auth           auth.Config
+	Throttle       throttle.Config
+	Health         health.Config
+	Concurrency    concurrency.Config
+	AdminKey       string        // Key of the admin API, empty disables it
+	IdempotencyTTL time.Duration // How long idempotent responses are replayed, DefaultIdempotencyTTL when unset
+}
+
+// NewWeatherService migrates the database and creates the service with its watcher and background jobs, they
+// start with Run
+func NewWeatherService(opts Options) (*WeatherService, error) {
+	logger := opts.Logger
+	database, err := db.InitialMigration(opts.DatabaseURL, logger, opts.Pool)
 	if err != nil {
 		return nil, err
 	}
-
-	wkr := worker.NewWorker(logger, *cfg, database)
-	watcher, err := watcher.NewWatcherSRV(database, logger, wkr)
-	if err != nil {
+	metrics.RegisterPool(database.Pool)
+	// Every query gets a span, the API and the watcher share the instrumented database
+	if err := tracing.InstrumentGorm(database.DB); err != nil {
 		return nil, err
 	}
 
-	// Create a connection pool with the specified maximum number of concurrent connections
-	dbPool, err := db.NewConnectionPool(database.DB, maxConcurrentConnections)
+	wkr := worker.NewWorker(logger, opts.Worker, database)
+	webhooks := webhook.NewDispatcher(database, logger, opts.Webhooks)
+	watcher, err := watcher.NewWatcherSRV(database, logger, wkr, webhooks)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create a semaphore with the specified maximum number of concurrent connections
-	semaphore := &sync.WaitGroup{}
-	semaphore.Add(maxConcurrentConnections)
-
-	return &WeatherService{
-		worker:    wkr,
-		watcher:   watcher,
-		Database:  database,
-		logger:    logger,
-		dbPool:    dbPool,
-		dbMutex:   sync.RWMutex{},
-		semaphore: semaphore,
-	}, nil
-}
+	// Bound the reports processed at the same time
+	reportLimiter := concurrency.NewLimiter(opts.Concurrency)
+	metrics.RegisterConcurrency(reportLimiterName, reportLimiter)
 
-func (r *WeatherService) Run() {
-	go r.watcher.Run()
-}
+	idempotencyTTL := opts.IdempotencyTTL
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = DefaultIdempotencyTTL
+	}
 
-func (r *WeatherService) getDBConnection() (*gorm.DB, error) {
-	r.dbMutex.Lock()
-	defer r.dbMutex.Unlock()
+	broker := stream.NewBroker(logger)
 
-	db, err := r.dbPool.AcquireConnection()
-	if err != nil {
-		return nil, err
+	limiter := throttle.NewLimiter(opts.Throttle)
+	metrics.RegisterThrottle(limiter)
+
+	healthCfg := opts.Health
+	if healthCfg.MaxHeadLag == 0 {
+		healthCfg.MaxHeadLag = health.DefaultMaxHeadLag
 	}
 
-	return db, nil
+	service := &WeatherService{
+		worker:     wkr,
+		watcher:    watcher,
+		Database:   database,
+		logger:     logger,
+		verifiers:  newSignatureVerifiers(wkr),
+		broker:     broker,
+		sequencer:  stream.NewSequencer(database, logger, broker, opts.Stream),
+		webhooks:   webhooks,
+		aggregator: aggregator.NewAggregator(database, logger, opts.Aggregation),
+		committer:  merkle.NewCommitter(database, logger, opts.Merkle),
+		adminKey:   opts.AdminKey,
+		sessions:   auth.NewTokenIssuer(opts.Auth),
+		throttle:   limiter,
+		health:     health.NewChecker(healthCfg.Timeout),
+
+		reportLimiter: reportLimiter,
+
+		idempotencyTTL: idempotencyTTL,
+	}
+	service.registerHealthChecks(healthCfg.MaxHeadLag)
+	return service, nil
 }
 
-func (r *WeatherService) releaseDBConnection(db *gorm.DB) {
-	r.dbMutex.Lock()
-	defer r.dbMutex.Unlock()
+func (r *WeatherService) Run() {
+	r.watcher.Run()
+	r.webhooks.Run()
+	r.sequencer.Run()
+	r.aggregator.Run()
+	r.committer.Run()
+}
 
-	r.dbPool.ReleaseConnection(db)
+// getDBConnection acquires a connection from the shared pool, waiting until ctx is done. Queries of the returned
+// session run with ctx.
+func (r *WeatherService) getDBConnection(ctx context.Context) (*gorm.DB, error) {
+	return r.Database.Pool.AcquireConnection(ctx)
 }
 
-func (r *WeatherService) Close() {
-	r.dbMutex.Lock()
-	defer r.dbMutex.Unlock()
+func (r *WeatherService) releaseDBConnection(db *gorm.DB) {
+	r.Database.Pool.ReleaseConnection(db)
+}
+
+// CloseStreams disconnects the report stream subscribers, so that their requests end before the servers drain
+func (r *WeatherService) CloseStreams() {
+	r.broker.Close()
+}
 
-	// Close all database connections in the connection pool
-	r.dbPool.CloseConnections()
+// Stop stops the watcher and the background jobs. The database and its connection pool are closed by their owner.
+func (r *WeatherService) Stop() {
+	r.watcher.Stop()
+	r.webhooks.Stop()
+	r.sequencer.Stop()
+	r.aggregator.Stop()
+	r.committer.Stop()
 }
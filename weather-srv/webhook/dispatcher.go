@@ -0,0 +1,335 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"gorm.io/gorm"
+)
+
+// Event types delivered to subscriptions
+const (
+	EventMemberRegistered = "member.registered"
+	EventMemberResigned   = "member.resigned"
+	EventReportCreated    = "report.created"
+)
+
+// EventTypes lists every event type a subscription can filter on
+var EventTypes = []string{EventMemberRegistered, EventMemberResigned, EventReportCreated}
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of a delivery as t=<unix>,v1=<hex>
+	SignatureHeader = "X-Webhook-Signature"
+	// EventHeader carries the event type of a delivery
+	EventHeader = "X-Webhook-Event"
+	// DeliveryHeader carries the ID of a delivery, retries keep the same ID
+	DeliveryHeader = "X-Webhook-Delivery"
+
+	deliveryBatchSize = 50
+	maxErrorLength    = 512
+)
+
+// Defaults used when the configuration leaves them unset
+const (
+	DefaultMaxAttempts  = 8
+	DefaultBaseBackoff  = 5 * time.Second
+	DefaultMaxBackoff   = time.Hour
+	DefaultPollInterval = 2 * time.Second
+	DefaultTimeout      = 10 * time.Second
+)
+
+// Config holds the webhook delivery settings
+type Config struct {
+	MaxAttempts  int           // Attempts before a delivery is marked failed
+	BaseBackoff  time.Duration // Delay before the first retry, doubled on every attempt
+	MaxBackoff   time.Duration // Upper bound of the retry delay
+	PollInterval time.Duration // Interval between two scans for due deliveries
+	Timeout      time.Duration // HTTP timeout of a delivery attempt
+}
+
+// Event is the JSON body of a delivery
+type Event struct {
+	Type      string      `json:"type"`
+	CreatedAt int64       `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher stores events as deliveries for the matching subscriptions and sends them with retries
+type Dispatcher struct {
+	DataBase *db.PostgresDataBase
+	Logger   *logrus.Logger
+	config   Config
+	client   *http.Client
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	wg       sync.WaitGroup // Running loop, waited for by Stop
+}
+
+// NewDispatcher creates a new Dispatcher instance
+func NewDispatcher(database *db.PostgresDataBase, logger *logrus.Logger, cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = DefaultBaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.MaxBackoff < cfg.BaseBackoff {
+		cfg.MaxBackoff = cfg.BaseBackoff
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	ctx, cancelFn := context.WithCancel(context.Background())
+	return &Dispatcher{
+		DataBase: database,
+		Logger:   logger,
+		config:   cfg,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		ctx:      ctx,
+		cancelFn: cancelFn,
+	}
+}
+
+// Sign returns the signature header value of body at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Subscribed reports whether the comma separated eventTypes filter includes eventType
+func Subscribed(eventTypes, eventType string) bool {
+	if strings.TrimSpace(eventTypes) == "" {
+		return true
+	}
+	for _, t := range strings.Split(eventTypes, ",") {
+		if strings.TrimSpace(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidEventType reports whether eventType is one of EventTypes
+func ValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Enqueue stores a pending delivery of every event data for each active subscription that includes eventType. It
+// runs in the transaction storing the change the events describe, so that the deliveries are committed with it
+// and sent even when the service stops right after.
+func (d *Dispatcher) Enqueue(tx *gorm.DB, eventType string, data ...interface{}) error {
+	subscriptions, err := db.FindActiveWebhookSubscriptions(tx)
+	if err != nil {
+		return fmt.Errorf("unable to load webhook subscriptions: %w", err)
+	}
+
+	var deliveries []db.WebhookDelivery
+	now := time.Now()
+	for _, event := range data {
+		body, err := json.Marshal(Event{Type: eventType, CreatedAt: now.Unix(), Data: event})
+		if err != nil {
+			return fmt.Errorf("unable to encode webhook event %s: %w", eventType, err)
+		}
+		for _, sub := range subscriptions {
+			if !Subscribed(sub.EventTypes, eventType) {
+				continue
+			}
+			deliveries = append(deliveries, db.WebhookDelivery{
+				SubscriptionID: sub.ID,
+				EventType:      eventType,
+				Payload:        string(body),
+				Status:         string(db.DeliveryPending),
+				NextAttemptAt:  now,
+			})
+		}
+	}
+	return db.CreateWebhookDeliveries(tx, deliveries)
+}
+
+// Run starts sending due deliveries until Stop is called
+func (d *Dispatcher) Run() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.processDeliveries()
+	}()
+}
+
+// Stop stops sending deliveries, pending ones are sent on next start. It returns once the attempt in flight is done,
+// the rest of its batch is released for the next start or another instance.
+func (d *Dispatcher) Stop() {
+	d.cancelFn()
+	d.wg.Wait()
+}
+
+// processDeliveries periodically sends the due deliveries
+func (d *Dispatcher) processDeliveries() {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.sendDueDeliveries()
+		case <-d.ctx.Done():
+			d.Logger.Info("Webhook dispatcher has stopped")
+			return
+		}
+	}
+}
+
+// claimLease returns how long claimed deliveries are hidden from other instances, long enough to attempt a full batch
+func (d *Dispatcher) claimLease() time.Duration {
+	return d.config.Timeout * deliveryBatchSize
+}
+
+// sendDueDeliveries claims the pending deliveries whose next attempt is due and attempts them. Deliveries not
+// attempted before Stop are released.
+func (d *Dispatcher) sendDueDeliveries() {
+	if d.ctx.Err() != nil {
+		return
+	}
+	database, err := d.DataBase.Pool.AcquireConnection(context.Background())
+	if err != nil {
+		d.Logger.Errorf("Unable to load due webhook deliveries: %v", err)
+		return
+	}
+	deliveries, err := db.ClaimDueWebhookDeliveries(database, time.Now(), d.claimLease(), deliveryBatchSize)
+	if err != nil {
+		d.DataBase.Pool.ReleaseConnection(database)
+		d.Logger.Errorf("Unable to claim due webhook deliveries: %v", err)
+		return
+	}
+	var subscriptions []db.WebhookSubscription
+	if len(deliveries) > 0 {
+		subscriptions, err = db.FindWebhookSubscriptions(database)
+	}
+	d.DataBase.Pool.ReleaseConnection(database)
+	if err != nil {
+		// The claimed deliveries are attempted once their lease expires
+		d.Logger.Errorf("Unable to load webhook subscriptions: %v", err)
+		return
+	}
+	byID := make(map[uint]db.WebhookSubscription, len(subscriptions))
+	for _, sub := range subscriptions {
+		byID[sub.ID] = sub
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		sub, ok := byID[delivery.SubscriptionID]
+		switch {
+		case d.ctx.Err() != nil:
+			// Stopping, save it unchanged to release the claim
+		case !ok || !sub.Active:
+			delivery.Status = string(db.DeliveryFailed)
+			delivery.LastError = "subscription removed or inactive"
+		default:
+			d.attempt(sub, delivery)
+		}
+		d.save(delivery)
+	}
+}
+
+// save stores the delivery, posts are not repeated when it fails as the claim only expires after its lease
+func (d *Dispatcher) save(delivery *db.WebhookDelivery) {
+	database, err := d.DataBase.Pool.AcquireConnection(context.Background())
+	if err != nil {
+		d.Logger.Errorf("Unable to save webhook delivery %d: %v", delivery.ID, err)
+		return
+	}
+	defer d.DataBase.Pool.ReleaseConnection(database)
+	if err := db.SaveWebhookDelivery(database, delivery); err != nil {
+		d.Logger.Errorf("Unable to save webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// attempt sends the delivery once and records the outcome, scheduling a retry with exponential backoff on failure
+func (d *Dispatcher) attempt(sub db.WebhookSubscription, delivery *db.WebhookDelivery) {
+	delivery.Attempts++
+
+	code, err := d.post(sub, delivery)
+	delivery.ResponseCode = code
+	if err == nil {
+		now := time.Now()
+		delivery.Status = string(db.DeliveryDelivered)
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxErrorLength {
+		delivery.LastError = delivery.LastError[:maxErrorLength]
+	}
+	if delivery.Attempts >= d.config.MaxAttempts {
+		delivery.Status = string(db.DeliveryFailed)
+		d.Logger.Warnf("Webhook delivery %d to %s failed after %d attempts: %v", delivery.ID, sub.URL, delivery.Attempts, err)
+		return
+	}
+	delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+}
+
+// backoff returns the delay before the next attempt after the given number of attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.config.MaxBackoff {
+			return d.config.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// post sends the signed delivery and returns the HTTP status, any non 2xx status is an error. The request is bounded
+// by the client timeout only, so that Stop lets it finish rather than recording a failed attempt.
+func (d *Dispatcher) post(sub db.WebhookSubscription, delivery *db.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, time.Now().Unix(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
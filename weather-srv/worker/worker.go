@@ -1,19 +1,28 @@
 package worker
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"os"
+	"strings"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 	"github.com/wankhede04/blockswap.weather/weather-srv/db"
+	"github.com/wankhede04/blockswap.weather/weather-srv/logging"
+	"github.com/wankhede04/blockswap.weather/weather-srv/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // WorkerConfig ...
@@ -24,6 +33,12 @@ type WorkerConfig struct {
 	StartBlockHeight     *big.Int       `json:"from_block"`
 }
 
+// erc1271ABI is the ABI of the EIP-1271 isValidSignature function
+const erc1271ABI = `[{"inputs":[{"internalType":"bytes32","name":"hash","type":"bytes32"},{"internalType":"bytes","name":"signature","type":"bytes"}],"name":"isValidSignature","outputs":[{"internalType":"bytes4","name":"magicValue","type":"bytes4"}],"stateMutability":"view","type":"function"}]`
+
+// erc1271MagicValue is returned by isValidSignature when the signature is valid
+var erc1271MagicValue = []byte{0x16, 0x26, 0xba, 0x7e}
+
 // Worker creates an instance and store its information
 type Worker struct {
 	provider             string
@@ -56,27 +71,41 @@ func NewWorker(Logger *logrus.Logger, cfg WorkerConfig, db *db.PostgresDataBase)
 		Logger.Fatal("Error loading .env file")
 	}
 
-	provider := cfg.Provider + os.Getenv("ARBITRUM_TESTNET_ALCHEMY_API_KEY")
+	// The API key is appended to the provider URL, it must never reach the logs
+	apiKey := os.Getenv("ARBITRUM_TESTNET_ALCHEMY_API_KEY")
+	logging.RegisterSecret(apiKey)
+	provider := cfg.Provider + apiKey
 	client, err := ethclient.Dial(provider)
 	if err != nil {
-		panic(fmt.Sprintf("rpc error for %s : %s", cfg.ChainName, err.Error()))
+		panic(logging.Redact(fmt.Sprintf("rpc error for %s : %s", cfg.ChainName, err.Error())))
 	}
 
-	chainid, err := client.ChainID(context.Background())
-	if err != nil {
-		panic("rpc not returning chain id")
-	}
-
-	return &Worker{
+	w := &Worker{
 		ChainName:            cfg.ChainName,
-		chainID:              chainid.Int64(),
-		Logger:               Logger.WithField("worker", cfg.ChainName),
+		Logger:               Logger.WithField("chain", cfg.ChainName),
 		provider:             cfg.Provider,
 		config:               cfg,
 		client:               client,
 		registrationContract: cfg.RegistrationContract,
 		DB:                   db,
 	}
+
+	chainid, err := w.FetchChainID(context.Background())
+	if err != nil {
+		panic("rpc not returning chain id")
+	}
+	w.chainID = chainid
+	w.Logger.WithField("provider", logging.RedactURL(provider)).Infof("Connected to chain %d", chainid)
+	return w
+}
+
+// startSpan starts the client span of an RPC call made with the ethclient
+func (w *Worker) startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "ethclient."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("rpc.method", method),
+		attribute.String("chain.name", w.ChainName),
+	))
 }
 
 func (w *Worker) GetChainID() int64 {
@@ -87,22 +116,88 @@ func (w *Worker) GetRegistrationContract() common.Address {
 	return w.registrationContract
 }
 
+// ErrNotContractAccount is returned by IsValidSignature for an account without code
+var ErrNotContractAccount = errors.New("not a contract account")
+
+// IsValidSignature calls EIP-1271 isValidSignature on the contract account and reports whether it returned the magic
+// value. A reverted call is an invalid signature, any other error means the check could not be made.
+func (w *Worker) IsValidSignature(ctx context.Context, account common.Address, hash [32]byte, signature []byte) (bool, error) {
+	spanCtx, span := w.startSpan(ctx, "eth_getCode")
+	code, err := w.client.CodeAt(spanCtx, account, nil)
+	tracing.End(span, err)
+	if err != nil {
+		return false, fmt.Errorf("IsValidSignature:%w", err)
+	}
+	if len(code) == 0 {
+		return false, fmt.Errorf("IsValidSignature: %s is %w", account.Hex(), ErrNotContractAccount)
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(erc1271ABI))
+	if err != nil {
+		return false, fmt.Errorf("IsValidSignature:%w", err)
+	}
+	input, err := parsed.Pack("isValidSignature", hash, signature)
+	if err != nil {
+		return false, fmt.Errorf("IsValidSignature:%w", err)
+	}
+
+	spanCtx, span = w.startSpan(ctx, "eth_call")
+	output, err := w.client.CallContract(spanCtx, ethereum.CallMsg{To: &account, Data: input}, nil)
+	tracing.End(span, err)
+	if err != nil {
+		// Contracts are allowed to revert on invalid signatures
+		if isExecutionReverted(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("IsValidSignature:%w", err)
+	}
+	if len(output) < len(erc1271MagicValue) {
+		return false, nil
+	}
+	return bytes.Equal(output[:len(erc1271MagicValue)], erc1271MagicValue), nil
+}
+
+// isExecutionReverted reports whether err is the node answering that the call reverted, rather than a failure to
+// reach it. Nodes attach the revert data to the error, or at least name the revert in the message.
+func isExecutionReverted(err error) bool {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "revert")
+}
+
+// FetchChainID returns the chain id the RPC provider reports now, to compare with the one read at startup
+func (w *Worker) FetchChainID(ctx context.Context) (int64, error) {
+	ctx, span := w.startSpan(ctx, "eth_chainId")
+	chainID, err := w.client.ChainID(ctx)
+	tracing.End(span, err)
+	if err != nil {
+		return 0, fmt.Errorf("FetchChainID:%w", err)
+	}
+	return chainID.Int64(), nil
+}
+
 // GetLatestBlock returns latest block
-func (w *Worker) GetLatestBlock() (*big.Int, error) {
-	latestBlock, err := w.client.HeaderByNumber(context.Background(), nil)
+func (w *Worker) GetLatestBlock(ctx context.Context) (*big.Int, error) {
+	ctx, span := w.startSpan(ctx, "eth_getBlockByNumber")
+	latestBlock, err := w.client.HeaderByNumber(ctx, nil)
+	tracing.End(span, err)
 	if err != nil {
 		return big.NewInt(0), err
 	}
 	return latestBlock.Number, nil
 }
 
-func (w *Worker) SubscribeToLogs(logs chan types.Log) (ethereum.Subscription, error) {
+// SubscribeToLogs subscribes to the logs of the registration contract from the last stored event, ctx only bounds
+// setting up the subscription
+func (w *Worker) SubscribeToLogs(ctx context.Context, logs chan types.Log) (ethereum.Subscription, error) {
 	var StartBlockHeight *big.Int
 	lastTxnLog, err := w.DB.FindLastEventLog(w.ChainName)
 	if err != nil {
 		StartBlockHeight = w.config.StartBlockHeight
 		if StartBlockHeight.Cmp(big.NewInt(0)) == 0 {
-			StartBlockHeight, err = w.GetLatestBlock()
+			StartBlockHeight, err = w.GetLatestBlock(ctx)
 			if err != nil {
 				return nil, fmt.Errorf("SubscribeToLogs:%w", err)
 			}
@@ -116,7 +211,9 @@ func (w *Worker) SubscribeToLogs(logs chan types.Log) (ethereum.Subscription, er
 		Addresses: []common.Address{w.config.RegistrationContract}, FromBlock: StartBlockHeight,
 	}
 
-	sub, err := w.client.SubscribeFilterLogs(context.Background(), query, logs)
+	spanCtx, span := w.startSpan(ctx, "eth_subscribe")
+	sub, err := w.client.SubscribeFilterLogs(spanCtx, query, logs)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("SubscribeToLogs:%w", err)
 	}